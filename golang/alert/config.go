@@ -0,0 +1,127 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// rule kind constants
+const (
+	TemperatureKind     = "temperature"
+	DoorOpenPercentKind = "door_open_percent"
+	ProbeErrorKind      = "probe_error"
+	BatteryChargeKind   = "battery_charge"
+	BatteryDaysKind     = "battery_days_remaining"
+	BatteryAgeKind      = "battery_age_days"
+	BatteryDropKind     = "battery_drop"
+)
+
+// Config defines the json alert rules configuration file
+type Config struct {
+	Rules     []Rule           `json:"rules"`
+	Notifiers []NotifierConfig `json:"notifiers"`
+}
+
+// Rule defines a single alert rule evaluated against every decoded packet
+type Rule struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind"`
+	Tags        []string `json:"tags,omitempty"`
+	Above       *float64 `json:"above,omitempty"`
+	Below       *float64 `json:"below,omitempty"`
+	Hysteresis  float64  `json:"hysteresis,omitempty"`
+	MinDuration Duration `json:"min_duration,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+}
+
+// NotifierConfig defines a single alert notification target
+type NotifierConfig struct {
+	Type    string            `json:"type"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+}
+
+// Duration is a time.Duration encoded in json as a duration string (e.g. "10m")
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses the json duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON encodes the duration as a json duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// LoadConfig reads and validates the given json alert rules configuration file
+func LoadConfig(path string) (*Config, error) {
+	// read the configuration file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse the configuration
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Invalid alert rules file '%s' (%v)", path, err)
+	}
+
+	// validate every rule
+	for i := range config.Rules {
+		if err := config.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("Invalid alert rule #%d in '%s' (%v)", i+1, path, err)
+		}
+	}
+
+	// return the configuration
+	return config, nil
+}
+
+func (r *Rule) validate() error {
+	// validate the rule kind
+	if _, ok := extractors[r.Kind]; !ok {
+		return fmt.Errorf("unknown rule kind '%s'", r.Kind)
+	}
+
+	// the probe error rule has an implicit threshold
+	if r.Kind == ProbeErrorKind && r.Above == nil && r.Below == nil {
+		zero := 0.0
+		r.Above = &zero
+	}
+
+	// validate the thresholds
+	if r.Above == nil && r.Below == nil {
+		return fmt.Errorf("rule '%s' needs an 'above' or 'below' threshold", r.Name)
+	}
+	if r.Hysteresis < 0 {
+		return fmt.Errorf("rule '%s' hysteresis cannot be negative", r.Name)
+	}
+
+	// default the rule name and severity
+	if r.Name == "" {
+		r.Name = r.Kind
+	}
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+
+	// return successfully
+	return nil
+}
@@ -0,0 +1,320 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// alert state constants
+const (
+	RaisedState  = "raised"
+	ClearedState = "cleared"
+)
+
+// extractFunc returns the rule value of the given decoded packet (or false if not included), given the
+// previous packet of the same tag (nil if this is the first packet)
+type extractFunc func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool)
+
+// extractors maps each rule kind to the function that extracts its value from a packet
+var extractors = map[string]extractFunc{
+	TemperatureKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		tempC, ok := p.Temperature()
+		return float64(tempC), ok
+	},
+	DoorOpenPercentKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		return statusNumber(p, "DOOR_OPEN_PERCENT")
+	},
+	ProbeErrorKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		// the probe error value is 1 while any probe error status is reported, otherwise 0
+		if _, ok := p.StatusValue("TEMP_PROBE_ERROR"); ok {
+			return 1, true
+		}
+		return 0, true
+	},
+	BatteryChargeKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		return float64(p.BatteryCharge()), true
+	},
+	BatteryDaysKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		return float64(p.Battery.Days), true
+	},
+	BatteryAgeKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		return float64(p.Battery.Age), true
+	},
+	BatteryDropKind: func(p *ccx.DecodedPacket, prev *ccx.DecodedPacket) (float64, bool) {
+		// the battery drop value is the charge percent lost since the previous packet
+		if prev == nil {
+			return 0, false
+		}
+		return float64(prev.BatteryCharge() - p.BatteryCharge()), true
+	},
+}
+
+// Engine evaluates the alert rules against every decoded packet and tracks the alert state of each tag
+type Engine struct {
+	mutex     sync.Mutex
+	rules     []Rule
+	notifiers []Notifier
+	states    map[stateKey]*ruleState
+	previous  map[string]*ccx.DecodedPacket
+}
+
+// Event defines a raised or cleared alert
+type Event struct {
+	Rule      string    `json:"rule"`
+	Kind      string    `json:"kind"`
+	Severity  string    `json:"severity"`
+	TagMAC    string    `json:"tag_mac"`
+	State     string    `json:"state"`
+	Value     float64   `json:"value"`
+	Threshold string    `json:"threshold"`
+	TS        time.Time `json:"ts"`
+	Since     time.Time `json:"since"`
+	Message   string    `json:"message"`
+}
+
+// stateKey identifies the alert state of a single rule and tag
+type stateKey struct {
+	rule int
+	tag  string
+}
+
+// ruleState tracks the alert state of a single rule and tag
+type ruleState struct {
+	active       bool
+	pendingSince time.Time
+	event        *Event
+}
+
+// NewEngine creates a new instance from the given alert rules configuration
+func NewEngine(config *Config) (*Engine, error) {
+	// create the notifiers
+	notifiers := []Notifier{}
+	for _, nc := range config.Notifiers {
+		n, err := NewNotifier(&nc)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+
+	// normalize the tag mac-address filters
+	rules := append([]Rule{}, config.Rules...)
+	for i := range rules {
+		for j, tag := range rules[i].Tags {
+			rules[i].Tags[j] = util.NormalizeMAC(tag)
+		}
+	}
+
+	// return the new instance
+	return &Engine{
+		rules:     rules,
+		notifiers: notifiers,
+		states:    map[stateKey]*ruleState{},
+		previous:  map[string]*ccx.DecodedPacket{},
+	}, nil
+}
+
+// Evaluate evaluates every rule against the given decoded packet received at the given time,
+// notifies every raised or cleared alert, and returns the alert events
+func (e *Engine) Evaluate(ts time.Time, packet *ccx.DecodedPacket) []*Event {
+	e.mutex.Lock()
+	events := []*Event{}
+	tag := packet.TagMAC()
+	prev := e.previous[tag]
+	for i := range e.rules {
+		r := &e.rules[i]
+
+		// if the rule does not apply to the tag
+		if !r.appliesTo(tag) {
+			continue
+		}
+
+		// if the packet does not include the rule value
+		v, ok := extractors[r.Kind](packet, prev)
+		if !ok {
+			continue
+		}
+
+		// evaluate the rule value
+		if event := e.evaluate(i, tag, ts, v); event != nil {
+			events = append(events, event)
+		}
+	}
+
+	// keep the packet for the rules comparing consecutive packets
+	e.previous[tag] = packet
+	e.mutex.Unlock()
+
+	// notify the alert events
+	e.notify(events)
+
+	// return the alert events
+	return events
+}
+
+// Active returns every currently raised alert, sorted by tag mac-address and rule
+func (e *Engine) Active() []*Event {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// copy every raised alert (the stored events keep being updated after the mutex is released)
+	events := []*Event{}
+	for _, s := range e.states {
+		if s.active {
+			event := *s.event
+			events = append(events, &event)
+		}
+	}
+
+	// sort the alerts
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].TagMAC != events[j].TagMAC {
+			return events[i].TagMAC < events[j].TagMAC
+		}
+		return events[i].Rule < events[j].Rule
+	})
+
+	// return the raised alerts
+	return events
+}
+
+// Handler returns the http handler serving the currently raised alerts as json
+func (e *Engine) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(e.Active())
+	})
+}
+
+// Close closes every notifier
+func (e *Engine) Close() error {
+	for _, n := range e.notifiers {
+		n.Close()
+	}
+	return nil
+}
+
+func (e *Engine) evaluate(i int, tag string, ts time.Time, v float64) *Event {
+	r := &e.rules[i]
+
+	// get the rule state of the tag (or create it if this is the first value)
+	key := stateKey{rule: i, tag: tag}
+	s, ok := e.states[key]
+	if !ok {
+		s = &ruleState{}
+		e.states[key] = s
+	}
+
+	// if the alert is not raised
+	if !s.active {
+		// if the value is within the thresholds, reset the pending violation
+		if !r.violated(v) {
+			s.pendingSince = time.Time{}
+			return nil
+		}
+
+		// the value must violate the thresholds for the minimum duration before raising
+		if s.pendingSince.IsZero() {
+			s.pendingSince = ts
+		}
+		if ts.Sub(s.pendingSince) < r.MinDuration.Duration {
+			return nil
+		}
+
+		// raise the alert (returning a copy, as the stored event keeps being updated)
+		s.active = true
+		s.event = r.newEvent(tag, RaisedState, v, ts, s.pendingSince)
+		raised := *s.event
+		return &raised
+	}
+
+	// keep the raised alert value up-to-date
+	s.event.Value = v
+
+	// the alert is cleared once the value is back within the thresholds by the hysteresis
+	if !r.cleared(v) {
+		return nil
+	}
+
+	// clear the alert
+	s.active = false
+	s.pendingSince = time.Time{}
+	return r.newEvent(tag, ClearedState, v, ts, s.event.Since)
+}
+
+func (e *Engine) notify(events []*Event) {
+	for _, event := range events {
+		for _, n := range e.notifiers {
+			if err := n.Notify(event); err != nil {
+				fmt.Printf("Error notifying alert '%s' for tag '%s' (error = '%v')\n", event.Rule, event.TagMAC, err)
+			}
+		}
+	}
+}
+
+func (r *Rule) appliesTo(tag string) bool {
+	// rules without tag filters apply to every tag
+	if len(r.Tags) == 0 {
+		return true
+	}
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) violated(v float64) bool {
+	return (r.Above != nil && v > *r.Above) || (r.Below != nil && v < *r.Below)
+}
+
+func (r *Rule) cleared(v float64) bool {
+	return (r.Above == nil || v <= *r.Above-r.Hysteresis) && (r.Below == nil || v >= *r.Below+r.Hysteresis)
+}
+
+func (r *Rule) threshold() string {
+	parts := []string{}
+	if r.Above != nil {
+		parts = append(parts, "above "+strconv.FormatFloat(*r.Above, 'f', -1, 64))
+	}
+	if r.Below != nil {
+		parts = append(parts, "below "+strconv.FormatFloat(*r.Below, 'f', -1, 64))
+	}
+	return strings.Join(parts, " or ")
+}
+
+func (r *Rule) newEvent(tag string, state string, v float64, ts time.Time, since time.Time) *Event {
+	return &Event{
+		Rule:      r.Name,
+		Kind:      r.Kind,
+		Severity:  r.Severity,
+		TagMAC:    tag,
+		State:     state,
+		Value:     v,
+		Threshold: r.threshold(),
+		TS:        ts,
+		Since:     since,
+		Message: fmt.Sprintf("Alert '%s' %s for tag '%s' (%s = %g, threshold %s)",
+			r.Name, state, tag, r.Kind, v, r.threshold()),
+	}
+}
+
+// statusNumber returns the numeric value of the given 'KEY=VALUE' status telemetry string
+func statusNumber(p *ccx.DecodedPacket, key string) (float64, bool) {
+	s, ok := p.StatusValue(key)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
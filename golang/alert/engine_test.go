@@ -0,0 +1,187 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func temperaturePacket(t *testing.T, tempC float32) *ccx.DecodedPacket {
+	p := ccx.NewPacket()
+	if err := p.SetTemperature(tempC); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestTemperatureHysteresisAndMinDuration(t *testing.T) {
+	config := &Config{Rules: []Rule{{
+		Kind:        TemperatureKind,
+		Above:       float(8),
+		Hysteresis:  0.5,
+		MinDuration: Duration{10 * time.Minute},
+	}}}
+	if err := config.Rules[0].validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	steps := []struct {
+		minutes int
+		tempC   float32
+		state   string
+	}{
+		{0, 9, ""},              // violation starts
+		{5, 9, ""},              // not yet the minimum duration
+		{10, 9, RaisedState},    // raised after the minimum duration
+		{11, 7.8, ""},           // within the hysteresis band, still raised
+		{12, 7.5, ClearedState}, // cleared
+		{13, 9, ""},             // a new violation must wait the minimum duration again
+		{14, 7, ""},             // violation ended before the minimum duration
+		{30, 9, ""},
+	}
+	for _, step := range steps {
+		events := e.Evaluate(start.Add(time.Duration(step.minutes)*time.Minute), temperaturePacket(t, step.tempC))
+		state := ""
+		if len(events) == 1 {
+			state = events[0].State
+		}
+		if len(events) > 1 || state != step.state {
+			t.Fatalf("minute %d (%.1f C): events = %+v, want state '%s'", step.minutes, step.tempC, events, step.state)
+		}
+	}
+	if n := len(e.Active()); n != 0 {
+		t.Errorf("active alerts = %d, want 0", n)
+	}
+}
+
+func TestProbeErrorRule(t *testing.T) {
+	config := &Config{Rules: []Rule{{Kind: ProbeErrorKind, Tags: []string{"112233445566"}}}}
+	if err := config.Rules[0].validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := NewEngine(config)
+
+	p := ccx.NewPacket()
+	p.SetProbeUnplugged()
+	data, _ := p.Pack()
+	decoded, _ := ccx.Decode(data)
+
+	events := e.Evaluate(time.Now(), decoded)
+	if len(events) != 1 || events[0].State != RaisedState || events[0].TagMAC != "11:22:33:44:55:66" {
+		t.Fatalf("events = %+v", events)
+	}
+	if n := len(e.Active()); n != 1 {
+		t.Errorf("active alerts = %d, want 1", n)
+	}
+}
+
+func batteryPacket(t *testing.T, percent uint8, days uint16) *ccx.DecodedPacket {
+	p := ccx.NewPacket()
+	p.SetBatteryInfo(&ccx.BatteryInfo{PercentRemaining: percent, DaysRemaining: days, AgeDays: 100})
+	data, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestBatteryRules(t *testing.T) {
+	config := &Config{Rules: []Rule{
+		{Name: "low", Kind: BatteryChargeKind, Below: float(30), Hysteresis: 10},
+		{Name: "drop", Kind: BatteryDropKind, Above: float(25)},
+		{Name: "days", Kind: BatteryDaysKind, Below: float(14)},
+	}}
+	for i := range config.Rules {
+		if err := config.Rules[i].validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, _ := NewEngine(config)
+
+	start := time.Unix(1700000000, 0)
+	steps := []struct {
+		percent uint8
+		days    uint16
+		want    []string
+	}{
+		{90, 300, nil},                                    // the first packet has no battery drop
+		{80, 250, nil},                                    // a small drop
+		{50, 200, []string{"drop raised"}},                // a sudden drop
+		{20, 10, []string{"low raised", "days raised"}},   // the drop stays raised
+		{30, 10, []string{"drop cleared"}},                // within the charge hysteresis band
+		{40, 20, []string{"low cleared", "days cleared"}}, // charged
+	}
+	for i, step := range steps {
+		events := e.Evaluate(start.Add(time.Duration(i)*time.Minute), batteryPacket(t, step.percent, step.days))
+		got := []string{}
+		for _, event := range events {
+			got = append(got, event.Rule+" "+event.State)
+		}
+		if strings.Join(got, ",") != strings.Join(step.want, ",") {
+			t.Fatalf("step %d (%d%%, %d days): events = %v, want %v", i, step.percent, step.days, got, step.want)
+		}
+	}
+}
+
+func TestActiveEventsAreCopies(t *testing.T) {
+	config := &Config{Rules: []Rule{{Kind: TemperatureKind, Above: float(8)}}}
+	if err := config.Rules[0].validate(); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the raised event and the active alerts are not updated by the later packets
+	start := time.Unix(1700000000, 0)
+	raised := e.Evaluate(start, temperaturePacket(t, 9))
+	active := e.Active()
+	e.Evaluate(start.Add(time.Minute), temperaturePacket(t, 10))
+	if len(raised) != 1 || raised[0].Value != 9 || len(active) != 1 || active[0].Value != 9 {
+		t.Fatalf("raised = %+v, active = %+v", raised, active)
+	}
+	if active := e.Active(); active[0].Value != 10 {
+		t.Errorf("updated active value = %v", active[0].Value)
+	}
+
+	// encoding the active alerts while packets are evaluated is race free (go test -race)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			e.Evaluate(start.Add(time.Duration(i)*time.Second), temperaturePacket(t, 9+float32(i%2)))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		json.NewEncoder(io.Discard).Encode(e.Active())
+	}
+	wg.Wait()
+}
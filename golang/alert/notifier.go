@@ -0,0 +1,130 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/output"
+)
+
+// notifier type constants
+const (
+	StdoutNotifierType  = "stdout"
+	WebhookNotifierType = "webhook"
+	ExecNotifierType    = "exec"
+	execTimeout         = 30 * time.Second
+)
+
+// Notifier sends alert event notifications
+type Notifier interface {
+	Notify(e *Event) error
+	Close() error
+}
+
+// NewNotifier creates the notifier of the given configuration
+func NewNotifier(config *NotifierConfig) (Notifier, error) {
+	switch config.Type {
+	case StdoutNotifierType:
+		return &stdoutNotifier{}, nil
+
+	case WebhookNotifierType:
+		// post each alert with the webhook output (retrying with backoff)
+		webhook, err := output.NewWebhook(&output.WebhookOptions{
+			URL:        config.URL,
+			Headers:    config.Headers,
+			MaxRetries: 3,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &webhookNotifier{webhook: webhook}, nil
+
+	case ExecNotifierType:
+		if config.Command == "" {
+			return nil, fmt.Errorf("Exec alert notifier needs a 'command'")
+		}
+		return &execNotifier{command: config.Command, args: config.Args}, nil
+
+	default:
+		return nil, fmt.Errorf("Unknown alert notifier type '%s'", config.Type)
+	}
+}
+
+// stdoutNotifier prints each alert to the console
+type stdoutNotifier struct{}
+
+func (n *stdoutNotifier) Notify(e *Event) error {
+	fmt.Printf("\nALERT %s: [%s] %s\n\n", e.TS.Format(time.RFC3339), e.Severity, e.Message)
+	return nil
+}
+
+func (n *stdoutNotifier) Close() error {
+	return nil
+}
+
+// webhookNotifier posts each alert as a json message to an http endpoint
+type webhookNotifier struct {
+	webhook *output.Webhook
+}
+
+func (n *webhookNotifier) Notify(e *Event) error {
+	return n.webhook.Publish(&output.Message{
+		Type:   output.AlertMessageType,
+		TS:     e.TS,
+		TagMAC: e.TagMAC,
+		Event:  e,
+	})
+}
+
+func (n *webhookNotifier) Close() error {
+	return n.webhook.Close()
+}
+
+// execNotifier runs a command for each alert with the alert json on stdin and the alert
+// fields in 'EMANATE_ALERT_*' environment variables
+type execNotifier struct {
+	command string
+	args    []string
+}
+
+func (n *execNotifier) Notify(e *Event) error {
+	// encode the alert as json
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// run the command in the background so slow hooks cannot block the receiver
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, n.command, n.args...)
+		cmd.Env = append(os.Environ(),
+			"EMANATE_ALERT_RULE="+e.Rule,
+			"EMANATE_ALERT_KIND="+e.Kind,
+			"EMANATE_ALERT_SEVERITY="+e.Severity,
+			"EMANATE_ALERT_STATE="+e.State,
+			"EMANATE_ALERT_TAG_MAC="+e.TagMAC,
+			"EMANATE_ALERT_VALUE="+strconv.FormatFloat(e.Value, 'f', -1, 64),
+			"EMANATE_ALERT_MESSAGE="+e.Message,
+		)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Printf("Error running alert hook '%s' (error = '%v', output = '%s')\n", n.command, err, out)
+		}
+	}()
+
+	// return successfully
+	return nil
+}
+
+func (n *execNotifier) Close() error {
+	return nil
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+const (
+	siteKey = "000102030405060708090a0b0c0d0e0f"
+	tagKey  = "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"
+)
+
+func packet(t *testing.T, tag string, seq uint16) []byte {
+	p := ccx.NewPacket()
+	p.SetSequenceNumber(seq)
+	p.SetTemperature(4.5)
+	if err := p.SetTagMACAddress(tag); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func sign(t *testing.T, data []byte, keyID uint8, hexKey string) []byte {
+	key, err := ParseKey(hexKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Sign(data, keyID, key)
+}
+
+func newVerifier(t *testing.T) *Verifier {
+	keyring, err := NewKeyring(&KeyringConfig{Keys: []KeyConfig{
+		{ID: 1, Key: siteKey},
+		{ID: 2, Key: tagKey, Tags: []string{"AA:AA:AA:AA:AA:AA"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewVerifier(&VerifierOptions{Keyring: keyring, ReplayWindow: 4})
+}
+
+func TestSignedPacketDecodes(t *testing.T) {
+	// the standard ccx fields are still decoded from a signed packet
+	data := sign(t, packet(t, "11:22:33:44:55:66", 1), 1, siteKey)
+	decoded, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tempC, ok := decoded.Temperature(); !ok || tempC != 4.5 {
+		t.Errorf("temperature = %v, %v", tempC, ok)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	v := newVerifier(t)
+	now := time.Unix(1700000000, 0)
+	unsigned := packet(t, "11:22:33:44:55:66", 1)
+
+	// a site key signed packet is verified and returned without the trailer
+	data, err := v.Verify(sign(t, unsigned, 1, siteKey), now)
+	if err != nil || string(data) != string(unsigned) {
+		t.Fatalf("Verify() = %x, %v", data, err)
+	}
+
+	// forged and unsigned packets are rejected
+	forged := sign(t, packet(t, "11:22:33:44:55:66", 2), 1, siteKey)
+	forged[len(forged)-TrailerSize-1] ^= 0xFF
+	tests := []struct {
+		data []byte
+		want error
+	}{
+		{packet(t, "11:22:33:44:55:66", 2), ErrMissingTrailer},
+		{forged, ErrBadSignature},
+		{sign(t, packet(t, "11:22:33:44:55:66", 2), 1, tagKey), ErrBadSignature},
+		{sign(t, packet(t, "11:22:33:44:55:66", 2), 2, tagKey), ErrUnknownKey},
+	}
+	for i, test := range tests {
+		if _, err := v.Verify(test.data, now); !errors.Is(err, test.want) {
+			t.Errorf("test #%d: Verify() error = %v, want %v", i+1, err, test.want)
+		}
+	}
+
+	// the per-tag key is only valid for its tag, and the site key is not valid for the tag
+	if _, err := v.Verify(sign(t, packet(t, "AA:AA:AA:AA:AA:AA", 1), 2, tagKey), now); err != nil {
+		t.Errorf("per-tag key error = %v", err)
+	}
+	if _, err := v.Verify(sign(t, packet(t, "AA:AA:AA:AA:AA:AA", 2), 1, siteKey), now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("site key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestVerifyRewrittenAPMAC(t *testing.T) {
+	v := newVerifier(t)
+	now := time.Unix(1700000000, 0)
+
+	// the copies of a packet forwarded by other access points (each rewriting its own mac-address)
+	// are still verified
+	signed := sign(t, packet(t, "11:22:33:44:55:66", 1), 1, siteKey)
+	for i, ap := range [][]byte{{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, {0x66, 0x55, 0x44, 0x33, 0x22, 0x11}} {
+		forwarded := append([]byte{}, signed...)
+		copy(forwarded[ccx.APMACOffset:], ap)
+		data, err := v.Verify(forwarded, now)
+		if err != nil {
+			t.Fatalf("copy #%d: Verify() error = %v", i+1, err)
+		}
+		if string(data[ccx.APMACOffset:ccx.APMACOffset+6]) != string(ap) {
+			t.Errorf("copy #%d: ap mac-address = %x", i+1, data[ccx.APMACOffset:ccx.APMACOffset+6])
+		}
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	v := newVerifier(t)
+	now := time.Unix(1700000000, 0)
+	steps := []struct {
+		seq     uint16
+		seconds int
+		want    error
+	}{
+		{65534, 0, nil},
+		{65534, 1, nil},               // a burst copy
+		{1, 2, nil},                   // the sequence number wraps
+		{65535, 3, nil},               // out of order within the window
+		{65534, 10, ErrReplayed},      // a late copy
+		{65533, 11, ErrOutsideWindow}, // too old
+		{2, 12, nil},
+	}
+	for _, step := range steps {
+		data := sign(t, packet(t, "11:22:33:44:55:66", step.seq), 1, siteKey)
+		if _, err := v.Verify(data, now.Add(time.Duration(step.seconds)*time.Second)); !errors.Is(err, step.want) {
+			t.Errorf("sequence %d: Verify() error = %v, want %v", step.seq, err, step.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// Keyring holds the authentication keys, by key id, of every tag (per-tag keys) or of the whole
+// site (keys without tags, valid for the tags without per-tag keys). Several key ids can be active
+// at once so keys can be rotated without rejecting the packets of the tags still using the previous
+// key.
+type Keyring struct {
+	siteKeys map[uint8][]byte
+	tagKeys  map[string]map[uint8][]byte
+}
+
+// KeyringConfig defines the json authentication keys file
+type KeyringConfig struct {
+	Keys []KeyConfig `json:"keys"`
+}
+
+// KeyConfig defines a single authentication key
+type KeyConfig struct {
+	ID   uint8    `json:"id"`
+	Key  string   `json:"key"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// NewKeyring creates a new instance from the given keys configuration
+func NewKeyring(config *KeyringConfig) (*Keyring, error) {
+	k := &Keyring{
+		siteKeys: map[uint8][]byte{},
+		tagKeys:  map[string]map[uint8][]byte{},
+	}
+	for _, kc := range config.Keys {
+		// decode the hex key
+		key, err := ParseKey(kc.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid authentication key #%d (%v)", kc.ID, err)
+		}
+
+		// add the site key (or the key of each tag)
+		if len(kc.Tags) == 0 {
+			k.siteKeys[kc.ID] = key
+			continue
+		}
+		for _, tag := range kc.Tags {
+			tag, err := util.ParseMAC(tag)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid authentication key #%d tag (%v)", kc.ID, err)
+			}
+			if k.tagKeys[tag] == nil {
+				k.tagKeys[tag] = map[uint8][]byte{}
+			}
+			k.tagKeys[tag][kc.ID] = key
+		}
+	}
+
+	// return the new instance
+	return k, nil
+}
+
+// LoadKeyring reads the given json authentication keys file
+func LoadKeyring(path string) (*Keyring, error) {
+	// read the keys file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse the keys
+	config := &KeyringConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("Invalid authentication keys file '%s' (%v)", path, err)
+	}
+
+	// return the keyring
+	return NewKeyring(config)
+}
+
+// ParseKey decodes the given hex authentication key (at least 16 bytes)
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is not hex encoded")
+	}
+	if len(key) < 16 {
+		return nil, fmt.Errorf("key is shorter than 16 bytes")
+	}
+	return key, nil
+}
+
+// Key returns the given key of the given tag (a tag with per-tag keys only accepts its own keys, so
+// a leaked site key cannot sign its packets)
+func (k *Keyring) Key(tagMAC string, keyID uint8) ([]byte, bool) {
+	if keys, ok := k.tagKeys[tagMAC]; ok {
+		key, ok := keys[keyID]
+		return key, ok
+	}
+	key, ok := k.siteKeys[keyID]
+	return key, ok
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+// authentication trailer constants
+//
+// The trailer is a vendor telemetry group appended after every other telemetry group, so decoders
+// that do not verify it still decode the standard CCX fields (the group is reported as unknown):
+//
+//	| group id (1) | group length (1) | key id (1) | hmac-sha256 (32) |
+//
+// The hmac-sha256 is calculated over every preceding packet byte, including the trailer group id,
+// group length, and key id, except the access point mac-address bytes, which are zeroed as every
+// access point forwarding the packet (or proxy) rewrites them.
+const (
+	TrailerGroupID     = 0xEA
+	TrailerGroupLength = 1 + sha256.Size
+	TrailerSize        = 2 + TrailerGroupLength
+
+	// apMACSize is the size of the access point mac-address excluded from the signature
+	apMACSize = 6
+)
+
+// authentication errors
+var (
+	ErrMissingTrailer = errors.New("missing authentication trailer")
+	ErrUnknownKey     = errors.New("unknown authentication key")
+	ErrBadSignature   = errors.New("invalid authentication signature")
+	ErrReplayed       = errors.New("replayed sequence number")
+	ErrOutsideWindow  = errors.New("sequence number outside the replay window")
+)
+
+// Sign returns a copy of the given packed packet bytes with the authentication trailer of the
+// given key appended
+func Sign(data []byte, keyID uint8, key []byte) []byte {
+	// copy the packet and append the trailer group header and key id
+	signed := make([]byte, 0, len(data)+TrailerSize)
+	signed = append(signed, data...)
+	signed = append(signed, TrailerGroupID, TrailerGroupLength, keyID)
+
+	// append the signature
+	return append(signed, signature(signed, key)...)
+}
+
+// signature calculates the hmac-sha256 of the given bytes (with the access point mac-address
+// zeroed)
+func signature(data []byte, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	if len(data) < ccx.APMACOffset+apMACSize {
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+	mac.Write(data[:ccx.APMACOffset])
+	mac.Write(make([]byte, apMACSize))
+	mac.Write(data[ccx.APMACOffset+apMACSize:])
+	return mac.Sum(nil)
+}
+
+// trailerKeyID returns the key id of the authentication trailer, or false if the packet does not
+// end with an authentication trailer
+func trailerKeyID(data []byte) (uint8, bool) {
+	if len(data) < TrailerSize {
+		return 0, false
+	}
+	trailer := data[len(data)-TrailerSize:]
+	if trailer[0] != TrailerGroupID || trailer[1] != TrailerGroupLength {
+		return 0, false
+	}
+	return trailer[2], true
+}
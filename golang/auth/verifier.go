@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// verifier default constants
+const (
+	DefaultReplayWindow    = 64
+	DefaultDuplicateWindow = 2 * time.Second
+)
+
+// Verifier verifies the authentication trailer and sequence number of every packet
+type Verifier struct {
+	mutex   sync.Mutex
+	options *VerifierOptions
+	tags    map[string]*replayState
+}
+
+// VerifierOptions provides the instance options
+type VerifierOptions struct {
+	Keyring *Keyring
+
+	// ReplayWindow is the number of sequence numbers before the highest accepted sequence number of
+	// a tag that are still accepted (e.g. packets received out of order)
+	ReplayWindow int
+
+	// DuplicateWindow is how long the copies of an accepted sequence number (e.g. burst copies
+	// forwarded by several access points) are still accepted
+	DuplicateWindow time.Duration
+}
+
+// replayState tracks the recently accepted sequence numbers of a single tag
+type replayState struct {
+	top  uint16
+	seen map[uint16]time.Time
+}
+
+// NewVerifier creates a new instance
+func NewVerifier(options *VerifierOptions) *Verifier {
+	// apply the default option values
+	if options.ReplayWindow <= 0 {
+		options.ReplayWindow = DefaultReplayWindow
+	}
+	if options.DuplicateWindow <= 0 {
+		options.DuplicateWindow = DefaultDuplicateWindow
+	}
+
+	// return the new instance
+	return &Verifier{
+		options: options,
+		tags:    map[string]*replayState{},
+	}
+}
+
+// Verify verifies the authentication trailer and sequence number of the given packet bytes received
+// at the given time, and returns the packet bytes without the trailer
+func (v *Verifier) Verify(data []byte, ts time.Time) ([]byte, error) {
+	// get the trailer key id
+	keyID, ok := trailerKeyID(data)
+	if !ok || len(data) < ccx.TelemetryDataOffset+TrailerSize {
+		return nil, ErrMissingTrailer
+	}
+
+	// get the key of the tag
+	tag := util.MACBytesToString(tagMAC(data))
+	key, ok := v.options.Keyring.Key(tag, keyID)
+	if !ok {
+		return nil, fmt.Errorf("%w (tag '%s', key id %d)", ErrUnknownKey, tag, keyID)
+	}
+
+	// verify the signature
+	signed := data[:len(data)-TrailerSize+3]
+	if !hmac.Equal(data[len(signed):], signature(signed, key)) {
+		return nil, fmt.Errorf("%w (tag '%s', key id %d)", ErrBadSignature, tag, keyID)
+	}
+
+	// check the sequence number of the authentic packet against the replay window
+	seq := binary.BigEndian.Uint16(data[ccx.SequenceOffset:])
+	if err := v.checkReplay(tag, seq, ts); err != nil {
+		return nil, fmt.Errorf("%w (tag '%s', sequence %d)", err, tag, seq)
+	}
+
+	// return the packet without the trailer
+	return data[:len(data)-TrailerSize], nil
+}
+
+// FailureReason returns the short reason of the given verification error (e.g. for metrics labels)
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingTrailer):
+		return "auth_missing"
+	case errors.Is(err, ErrUnknownKey):
+		return "auth_unknown_key"
+	case errors.Is(err, ErrBadSignature):
+		return "auth_bad_signature"
+	case errors.Is(err, ErrReplayed):
+		return "auth_replayed"
+	case errors.Is(err, ErrOutsideWindow):
+		return "auth_outside_window"
+	default:
+		return "auth_error"
+	}
+}
+
+// checkReplay accepts the given sequence number of the given tag if it is new, or a recent copy of an
+// accepted sequence number
+func (v *Verifier) checkReplay(tag string, seq uint16, ts time.Time) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	// accept the first sequence number of the tag
+	s, ok := v.tags[tag]
+	if !ok {
+		v.tags[tag] = &replayState{top: seq, seen: map[uint16]time.Time{seq: ts}}
+		return nil
+	}
+
+	// the sequence number distance from the highest accepted sequence number (wrapping at 65536)
+	distance := int16(seq - s.top)
+
+	switch {
+	case distance > 0:
+		// a new highest sequence number, forget the sequence numbers that left the window
+		s.top = seq
+		for old := range s.seen {
+			if int(int16(s.top-old)) >= v.options.ReplayWindow || int16(s.top-old) < 0 {
+				delete(s.seen, old)
+			}
+		}
+
+	case -int(distance) >= v.options.ReplayWindow:
+		return ErrOutsideWindow
+
+	default:
+		// a copy of an accepted sequence number is only accepted within the duplicate window
+		if first, ok := s.seen[seq]; ok {
+			if ts.Sub(first) > v.options.DuplicateWindow {
+				return ErrReplayed
+			}
+			return nil
+		}
+	}
+
+	// accept the sequence number
+	s.seen[seq] = ts
+	return nil
+}
+
+// tagMAC returns the tag mac-address bytes of the given packet bytes
+func tagMAC(data []byte) [6]byte {
+	mac := [6]byte{}
+	copy(mac[:], data[ccx.TagMACOffset:])
+	return mac
+}
@@ -2,7 +2,7 @@
 
 # global variables
 BUILD_DIR=build
-CMDS="emanate_udp_sender emanate_udp_receiver"
+CMDS="emanate_udp_sender emanate_udp_receiver emanate_udp_proxy"
 
 # create the build directory
 mkdir -p $BUILD_DIR
@@ -11,13 +11,13 @@ mkdir -p $BUILD_DIR
 echo ""
 for cmd in $CMDS; do
    echo "Building '${cmd}' executable for OSX target";
-   GOOS=darwin GOARCH=386 go build -o build/${cmd}_osx cmd/${cmd}/main.go
+   GOOS=darwin GOARCH=386 go build -o build/${cmd}_osx ./cmd/${cmd}
 
    echo "Building '${cmd}' executable for Windows target";
-   GOOS=windows GOARCH=386 go build -o build/${cmd}.exe cmd/${cmd}/main.go
+   GOOS=windows GOARCH=386 go build -o build/${cmd}.exe ./cmd/${cmd}
 
    echo "Building '${cmd}' executable for Linux x86 target";
-   GOOS=linux GOARCH=386 go build -o build/${cmd}_linux_x86 cmd/${cmd}/main.go
+   GOOS=linux GOARCH=386 go build -o build/${cmd}_linux_x86 ./cmd/${cmd}
 done
 
 echo "DONE!"
@@ -0,0 +1,214 @@
+package burst
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+// correlator default constants
+const (
+	DefaultWindow     = 500 * time.Millisecond
+	LocationEventType = "location"
+)
+
+// Correlator groups the copies of a single tag burst forwarded by several access points
+type Correlator struct {
+	mutex   sync.Mutex
+	options *CorrelatorOptions
+	bursts  map[burstKey]*Burst
+	windows sync.WaitGroup
+	closed  bool
+}
+
+// CorrelatorOptions provides the instance options
+type CorrelatorOptions struct {
+	// Window is how long the copies of a burst are collected after the first copy is received
+	Window time.Duration
+
+	// Handler is called with every consolidated burst once its window ends
+	Handler func(b *Burst)
+}
+
+// Burst defines the consolidated copies of a single tag burst
+type Burst struct {
+	Type     string    `json:"type"`
+	TagMAC   string    `json:"tag_mac"`
+	Sequence uint16    `json:"sequence"`
+	TS       time.Time `json:"ts"`
+	APMAC    string    `json:"ap_mac"`
+	Location string    `json:"location,omitempty"`
+	Copies   []*Copy   `json:"copies"`
+
+	// the decoded packet of the first copy (not encoded)
+	Packet *ccx.DecodedPacket `json:"-"`
+
+	done  bool
+	timer *time.Timer
+}
+
+// Copy defines the copies of a burst forwarded by a single access point
+type Copy struct {
+	APMAC    string    `json:"ap_mac"`
+	Channel  uint8     `json:"channel"`
+	Received int       `json:"received"`
+	Weight   float64   `json:"weight"`
+	TS       time.Time `json:"ts"`
+}
+
+// burstKey identifies a single tag burst
+type burstKey struct {
+	tag      string
+	sequence uint16
+}
+
+// NewCorrelator creates a new instance
+func NewCorrelator(options *CorrelatorOptions) *Correlator {
+	// apply the default option values
+	if options.Window <= 0 {
+		options.Window = DefaultWindow
+	}
+
+	// return the new instance
+	return &Correlator{
+		options: options,
+		bursts:  map[burstKey]*Burst{},
+	}
+}
+
+// Add adds the given decoded packet received at the given time to its burst (the first copy starts
+// the burst window, and copies received after the window ends are ignored)
+func (c *Correlator) Add(ts time.Time, packet *ccx.DecodedPacket) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// if already closed
+	if c.closed {
+		return
+	}
+
+	// get the burst (or start it if this is the first copy)
+	key := burstKey{tag: packet.TagMAC(), sequence: packet.EmanateHeader.Sequence}
+	b, ok := c.bursts[key]
+	if !ok {
+		b = &Burst{
+			Type:     LocationEventType,
+			TagMAC:   key.tag,
+			Sequence: key.sequence,
+			TS:       ts,
+			Packet:   packet,
+		}
+		c.bursts[key] = b
+		c.windows.Add(1)
+		b.timer = time.AfterFunc(c.options.Window, func() {
+			defer c.windows.Done()
+			c.flush(key)
+		})
+	}
+
+	// ignore the late copies of a consolidated burst
+	if b.done {
+		return
+	}
+
+	// count the copy (the first copy from each access point is kept, and its repeats are counted)
+	ap := packet.APMAC()
+	for _, cp := range b.Copies {
+		if cp.APMAC == ap {
+			cp.Received++
+			return
+		}
+	}
+	b.Copies = append(b.Copies, &Copy{
+		APMAC:    ap,
+		Channel:  packet.Header.Channel,
+		Received: 1,
+		TS:       ts,
+	})
+}
+
+// Close consolidates the pending bursts now (calling the handler before returning), and ignores the
+// copies added later
+func (c *Correlator) Close() {
+	// stop the window timers of the pending bursts
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.closed = true
+	pending := []*Burst{}
+	for _, b := range c.bursts {
+		if !b.done && b.timer.Stop() {
+			pending = append(pending, b)
+			c.windows.Done()
+		}
+	}
+	c.mutex.Unlock()
+
+	// consolidate the pending bursts in their start order
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].TS.Before(pending[j].TS)
+	})
+	for _, b := range pending {
+		c.flush(burstKey{tag: b.TagMAC, sequence: b.Sequence})
+	}
+
+	// wait for the bursts whose windows were already ending
+	c.windows.Wait()
+}
+
+// flush consolidates the given burst and calls the handler
+func (c *Correlator) flush(key burstKey) {
+	c.mutex.Lock()
+	b := c.bursts[key]
+	b.done = true
+	b.consolidate()
+	closed := c.closed
+	c.mutex.Unlock()
+
+	// notify the consolidated burst (the bursts are no longer tracked once closed)
+	if closed {
+		c.notify(b)
+		return
+	}
+
+	// forget the burst once its late copies can no longer arrive
+	time.AfterFunc(c.options.Window, func() {
+		c.mutex.Lock()
+		delete(c.bursts, key)
+		c.mutex.Unlock()
+	})
+
+	// notify the consolidated burst
+	c.notify(b)
+}
+
+func (c *Correlator) notify(b *Burst) {
+	if c.options.Handler != nil {
+		c.options.Handler(b)
+	}
+}
+
+// consolidate weights every access point by its share of the received copies and picks the access
+// point that received the most copies (the first received copy wins a tie)
+func (b *Burst) consolidate() {
+	// calculate the copy weights
+	total := 0
+	for _, cp := range b.Copies {
+		total += cp.Received
+	}
+	for _, cp := range b.Copies {
+		cp.Weight = float64(cp.Received) / float64(total)
+	}
+
+	// sort the copies by weight, strongest first
+	sort.SliceStable(b.Copies, func(i, j int) bool {
+		return b.Copies[i].Received > b.Copies[j].Received
+	})
+
+	// pick the strongest access point
+	b.APMAC = b.Copies[0].APMAC
+}
@@ -0,0 +1,98 @@
+package burst
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+func burstCopy(t *testing.T, seq uint16, ap string) *ccx.DecodedPacket {
+	p := ccx.NewPacket()
+	p.SetSequenceNumber(seq)
+	if err := p.SetAPMACAddress(ap); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestCorrelator(t *testing.T) {
+	bursts := make(chan *Burst, 10)
+	c := NewCorrelator(&CorrelatorOptions{
+		Window: 200 * time.Millisecond,
+		Handler: func(b *Burst) {
+			bursts <- b
+		},
+	})
+
+	// three access points forward the same burst (one receives two copies), and another burst follows (the copies
+	// are decoded first so they are all added within the window)
+	copies := []*ccx.DecodedPacket{
+		burstCopy(t, 7, "AA:AA:AA:AA:AA:01"),
+		burstCopy(t, 7, "AA:AA:AA:AA:AA:02"),
+		burstCopy(t, 7, "AA:AA:AA:AA:AA:02"),
+		burstCopy(t, 7, "AA:AA:AA:AA:AA:03"),
+		burstCopy(t, 8, "AA:AA:AA:AA:AA:01"),
+	}
+	now := time.Now()
+	for _, cp := range copies {
+		c.Add(now, cp)
+	}
+
+	// each burst is consolidated once (both windows end together, so in any order)
+	consolidated := map[uint16]*Burst{}
+	for i := 0; i < 2; i++ {
+		b := <-bursts
+		consolidated[b.Sequence] = b
+	}
+	if b := consolidated[7]; b == nil || b.APMAC != "AA:AA:AA:AA:AA:02" || len(b.Copies) != 3 || b.Copies[0].Received != 2 || b.Copies[0].Weight != 0.5 ||
+		b.Copies[1].Weight != 0.25 {
+		t.Fatalf("burst = %+v", b)
+	}
+	if b := consolidated[8]; b == nil || b.APMAC != "AA:AA:AA:AA:AA:01" || len(b.Copies) != 1 || b.Copies[0].Weight != 1 {
+		t.Fatalf("burst = %+v", b)
+	}
+
+	// late copies of a consolidated burst are ignored
+	c.Add(time.Now(), burstCopy(t, 7, "AA:AA:AA:AA:AA:04"))
+	select {
+	case b := <-bursts:
+		t.Fatalf("unexpected burst = %+v", b)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestCorrelatorClose(t *testing.T) {
+	bursts := []*Burst{}
+	c := NewCorrelator(&CorrelatorOptions{
+		Window: time.Hour,
+		Handler: func(b *Burst) {
+			bursts = append(bursts, b)
+		},
+	})
+
+	// the pending bursts are consolidated when closed (without waiting for their windows)
+	now := time.Now()
+	c.Add(now, burstCopy(t, 7, "AA:AA:AA:AA:AA:01"))
+	c.Add(now.Add(time.Millisecond), burstCopy(t, 8, "AA:AA:AA:AA:AA:01"))
+	c.Add(now.Add(2*time.Millisecond), burstCopy(t, 7, "AA:AA:AA:AA:AA:02"))
+	c.Close()
+	if len(bursts) != 2 || bursts[0].Sequence != 7 || len(bursts[0].Copies) != 2 || bursts[1].Sequence != 8 {
+		t.Fatalf("bursts = %+v", bursts)
+	}
+
+	// the copies added once closed are ignored
+	c.Add(time.Now(), burstCopy(t, 9, "AA:AA:AA:AA:AA:01"))
+	c.Close()
+	if len(bursts) != 2 {
+		t.Fatalf("bursts = %+v", bursts)
+	}
+}
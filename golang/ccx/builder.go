@@ -0,0 +1,192 @@
+package ccx
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// builder validation constants
+const (
+	MaxBatteryTolerance = 70  // the battery tolerance is encoded in 3 bits of 10% steps
+	MaxStatusLength     = 126 // the utf-16 status string length (and its group length) must fit a byte
+	MinTemperature      = -273.15
+)
+
+// Builder builds a packet from chained options, collecting every validation error until Build is
+// called (the options are applied in order, so the telemetry entries keep the order of the calls)
+type Builder struct {
+	packet *Packet
+	errs   []error
+}
+
+// BuildError holds every validation error of a builder
+type BuildError struct {
+	Errors []error
+}
+
+// Error returns every validation error message
+func (e *BuildError) Error() string {
+	messages := []string{}
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("Invalid CCX packet (%s)", strings.Join(messages, "; "))
+}
+
+// Unwrap returns the validation errors (for errors.Is and errors.As)
+func (e *BuildError) Unwrap() []error {
+	return e.Errors
+}
+
+// NewBuilder creates a new instance starting from the default packet values
+func NewBuilder() *Builder {
+	return &Builder{
+		packet: NewPacket(),
+	}
+}
+
+// WithTagMAC sets the tag mac-address
+func (b *Builder) WithTagMAC(mac string) *Builder {
+	if err := b.packet.SetTagMACAddress(mac); err != nil {
+		b.fail("invalid tag mac-address (%v)", err)
+	}
+	return b
+}
+
+// WithAPMAC sets the access point mac-address
+func (b *Builder) WithAPMAC(mac string) *Builder {
+	if err := b.packet.SetAPMACAddress(mac); err != nil {
+		b.fail("invalid AP mac-address (%v)", err)
+	}
+	return b
+}
+
+// WithSequence sets the sequence number
+func (b *Builder) WithSequence(seq uint16) *Builder {
+	b.packet.SetSequenceNumber(seq)
+	return b
+}
+
+// WithTransmitPower sets the transmit power (dBm)
+func (b *Builder) WithTransmitPower(power uint8) *Builder {
+	b.packet.SetTransmitPower(power)
+	return b
+}
+
+// WithBurstLength sets the number of copies of each packet (at least 1)
+func (b *Builder) WithBurstLength(length int) *Builder {
+	if length < 1 || length > math.MaxUint8 {
+		b.fail("burst length '%d' must be between 1 and %d", length, math.MaxUint8)
+		return b
+	}
+	b.packet.SetBurstLength(uint8(length))
+	return b
+}
+
+// WithProductType sets the system group product type
+func (b *Builder) WithProductType(pt uint16) *Builder {
+	b.packet.SetProductType(pt)
+	return b
+}
+
+// WithBattery sets the battery group (the percentages are encoded in 10% steps, rounded down)
+func (b *Builder) WithBattery(chargePercent, tolerancePercent, daysRemaining, ageDays int) *Builder {
+	errs := len(b.errs)
+	if chargePercent < 0 || chargePercent > 100 {
+		b.fail("battery charge '%d%%' must be between 0 and 100", chargePercent)
+	}
+	if tolerancePercent < 0 || tolerancePercent > MaxBatteryTolerance {
+		b.fail("battery tolerance '%d%%' must be between 0 and %d", tolerancePercent, MaxBatteryTolerance)
+	}
+	if daysRemaining < 0 || daysRemaining > math.MaxUint16 {
+		b.fail("battery days remaining '%d' must be between 0 and %d", daysRemaining, math.MaxUint16)
+	}
+	if ageDays < 0 || int64(ageDays) > math.MaxUint32 {
+		b.fail("battery age '%d' days must be between 0 and %d", ageDays, uint32(math.MaxUint32))
+	}
+	if len(b.errs) == errs {
+		b.packet.SetBatteryInfo(&BatteryInfo{
+			TolerancePercent: uint8(tolerancePercent),
+			PercentRemaining: uint8(chargePercent),
+			DaysRemaining:    uint16(daysRemaining),
+			AgeDays:          uint32(ageDays),
+		})
+	}
+	return b
+}
+
+// WithTemperature adds a temperature telemetry entry (celsius)
+func (b *Builder) WithTemperature(celsius float64) *Builder {
+	if math.IsNaN(celsius) || celsius < MinTemperature || celsius > math.MaxFloat32 {
+		b.fail("temperature '%g' must be a number of degrees celsius above absolute zero", celsius)
+		return b
+	}
+	b.check(b.packet.SetTemperature(float32(celsius)))
+	return b
+}
+
+// WithStatus adds a status string telemetry entry (e.g. 'BUTTON=PRESSED')
+func (b *Builder) WithStatus(status string) *Builder {
+	switch {
+	case status == "":
+		b.fail("status must not be empty")
+	case len(status) > MaxStatusLength:
+		b.fail("status '%s' must not be longer than %d characters", status, MaxStatusLength)
+	case strings.IndexFunc(status, func(r rune) bool { return r < 0x20 || r > 0x7E }) >= 0:
+		b.fail("status '%s' must only include printable ascii characters", status)
+	default:
+		b.check(b.packet.WriteStatusTelemetry(NewStatusTelemetry(status)))
+	}
+	return b
+}
+
+// WithUtilState adds a utility state status telemetry entry from its short name ('unplugged', 'off',
+// 'idle', or 'active')
+func (b *Builder) WithUtilState(name string) *Builder {
+	for status, short := range UtilStateNames {
+		if strings.EqualFold(name, short) {
+			return b.WithStatus(status)
+		}
+	}
+	b.fail("util-state '%s' must be either 'unplugged', 'off', 'idle', or 'active'", name)
+	return b
+}
+
+// WithDoorOpenPercent adds the door-open status telemetry entry
+func (b *Builder) WithDoorOpenPercent(percent int) *Builder {
+	if percent < 0 || percent > 100 {
+		b.fail("door-open percent '%d' must be between 0 and 100", percent)
+		return b
+	}
+	return b.WithStatus(fmt.Sprintf("DOOR_OPEN_PERCENT=%d", percent))
+}
+
+// WithHighPowerPercent adds the high-power-mode status telemetry entry
+func (b *Builder) WithHighPowerPercent(percent int) *Builder {
+	if percent < 0 || percent > 100 {
+		b.fail("high-power percent '%d' must be between 0 and 100", percent)
+		return b
+	}
+	return b.WithStatus(fmt.Sprintf("HIGH_POWER_MODE_PERCENT=%d", percent))
+}
+
+// Build returns the packet, or a BuildError with every validation error
+func (b *Builder) Build() (*Packet, error) {
+	if len(b.errs) > 0 {
+		return nil, &BuildError{Errors: b.errs}
+	}
+	return b.packet, nil
+}
+
+// fail collects the given validation error
+func (b *Builder) fail(format string, args ...interface{}) {
+	b.errs = append(b.errs, fmt.Errorf(format, args...))
+}
+
+// check collects the given error if any
+func (b *Builder) check(err error) {
+	if err != nil {
+		b.errs = append(b.errs, err)
+	}
+}
@@ -0,0 +1,70 @@
+package ccx
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	// build a packet from chained options
+	packet, err := NewBuilder().
+		WithTagMAC("00:11:22:33:44:55").
+		WithAPMAC("AA:BB:CC:DD:EE:FF").
+		WithSequence(42).
+		WithBattery(90, 20, 365, 1000).
+		WithTemperature(21.5).
+		WithUtilState("idle").
+		WithStatus(ButtonPressedTelemetry).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := packet.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the packet decodes to the given values
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.TagMAC() != "00:11:22:33:44:55" || decoded.APMAC() != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("mac-addresses = %s, %s", decoded.TagMAC(), decoded.APMAC())
+	}
+	if temp, ok := decoded.Temperature(); !ok || temp != 21.5 {
+		t.Errorf("temperature = %v, %v", temp, ok)
+	}
+	if state, ok := decoded.UtilState(); !ok || state != "idle" {
+		t.Errorf("util-state = %v, %v", state, ok)
+	}
+	if statuses := decoded.Statuses(); len(statuses) != 2 || statuses[1] != ButtonPressedTelemetry {
+		t.Errorf("statuses = %v", statuses)
+	}
+	if decoded.BatteryCharge() != 90 || decoded.BatteryTolerance() != 20 {
+		t.Errorf("battery = %d%%, %d%%", decoded.BatteryCharge(), decoded.BatteryTolerance())
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	// every invalid option is reported
+	packet, err := NewBuilder().
+		WithTagMAC("00:11:22:33:44:5Z").
+		WithBattery(150, 10, 100, 10).
+		WithTemperature(math.NaN()).
+		WithStatus("").
+		WithUtilState("asleep").
+		WithDoorOpenPercent(50).
+		Build()
+	if packet != nil {
+		t.Errorf("packet = %+v", packet)
+	}
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("error = %v", err)
+	}
+	if len(buildErr.Errors) != 5 {
+		t.Errorf("%d errors: %v", len(buildErr.Errors), err)
+	}
+}
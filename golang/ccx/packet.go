@@ -35,9 +35,20 @@ const (
 	ButtonPressedTelemetry      = "BUTTON=PRESSED"
 	ProbeUnpluggedTelemetry     = "TEMP_PROBE_ERROR=UNPLUGGED"
 	ProbeInvalidValueTelemetry  = "TEMP_PROBE_ERROR=INVALID_VALUE"
+	TagMACOffset                = 2
+	APMACOffset                 = 8
+	SequenceOffset              = 14
 	TelemetryDataOffset         = 34
 )
 
+// UtilStateNames maps each utility state telemetry string to its short name
+var UtilStateNames = map[string]string{
+	UtilStateUnplugged:       "unplugged",
+	UtilStatePluggedInOff:    "off",
+	UtilStatePluggedInIdle:   "idle",
+	UtilStatePluggedInActive: "active",
+}
+
 // Packet instance struct
 type Packet struct {
 	EmanateHeader EmanateHeader
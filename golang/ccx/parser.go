@@ -3,149 +3,316 @@ package ccx
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
 )
 
+// decode errors returned (wrapped with the packet offset) when the packet is truncated or malformed
+var (
+	ErrTruncatedPacket      = errors.New("Truncated or malformed static fields")
+	ErrTruncatedGroup       = errors.New("Truncated or malformed group")
+	ErrMalformedTemperature = errors.New("Truncated or malformed temperature group")
+	ErrMalformedStatus      = errors.New("Truncated or malformed status telemetry group")
+)
+
+// DecodedPacket defines the fully decoded packet including the variable length telemetry entries
+type DecodedPacket struct {
+	ParsedPacket
+	Telemetry []DecodedTelemetry
+}
+
+// DecodedTelemetry defines a single telemetry entry decoded from the telemetry group data
+type DecodedTelemetry struct {
+	GroupID      uint8
+	GroupLength  uint8
+	Type         uint8
+	Celsius      float32
+	StatusLength uint8
+	Status       string
+}
+
 // Parse parses the given packet instance and dumps to the console
 func Parse(data []byte) error {
-	// parse the packet (everything but the variable length telemetry fields)
-	packet := ParsedPacket{}
-	buf := bytes.NewReader(data)
-	if err := binary.Read(buf, binary.BigEndian, &packet); err != nil {
+	// decode the packet
+	packet, err := Decode(data)
+
+	// if the static packet fields could not be decoded
+	if packet == nil {
 		return err
 	}
 
-	// dump the static packet info
-	dumpStaticInfo(&packet)
+	// dump the decoded packet info
+	Dump(packet)
+
+	// if the telemetry data is truncated or malformed
+	if err != nil {
+		// log the error
+		fmt.Printf("\nERROR: %s\n\n", err)
+	}
+
+	// return whether an error occurred
+	return err
+}
 
-	// get the start of the telemetry data
+// Decode decodes the given packet bytes into a decoded packet instance. If the static packet
+// fields are decoded but the telemetry data is malformed, the partially decoded packet is
+// returned along with the error.
+func Decode(data []byte) (*DecodedPacket, error) {
+	// decode the packet (everything but the variable length telemetry fields)
+	packet := &DecodedPacket{}
+	buf := bytes.NewReader(data)
+	if err := binary.Read(buf, binary.BigEndian, &packet.ParsedPacket); err != nil {
+		return nil, fmt.Errorf("%w in Emanate CCX UDP packet (%d bytes)", ErrTruncatedPacket, len(data))
+	}
+
+	// get the start of the telemetry data (all static fields were read, so the offset is in bounds)
 	telemetryData := data[TelemetryDataOffset:]
 
+	// decode the telemetry entries
+	telemetry, err := decodeTelemetry(telemetryData)
+	packet.Telemetry = telemetry
+
+	// return the decoded packet and whether an error occurred
+	return packet, err
+}
+
+// Dump dumps the given decoded packet to the console
+func Dump(packet *DecodedPacket) {
+	Fdump(os.Stdout, packet)
+}
+
+// Fdump dumps the given decoded packet to the given writer
+func Fdump(w io.Writer, packet *DecodedPacket) {
+	// dump the static packet info
+	dumpStaticInfo(w, &packet.ParsedPacket)
+
 	// dump the telemetry info
-	err := dumpTelemetryInfo(telemetryData)
+	dumpTelemetryInfo(w, packet.Telemetry)
+}
 
-	// return whether an error occurred
-	return err
+// TagMAC returns the formatted tag mac-address of the decoded packet
+func (p *DecodedPacket) TagMAC() string {
+	return util.MACBytesToString(p.EmanateHeader.TagMACAddr)
+}
+
+// APMAC returns the formatted wifi AP mac-address of the decoded packet
+func (p *DecodedPacket) APMAC() string {
+	return util.MACBytesToString(p.EmanateHeader.APMACAddr)
 }
 
-func dumpStaticInfo(packet *ParsedPacket) {
+// Statuses returns all of the decoded status telemetry strings in packet order
+func (p *DecodedPacket) Statuses() []string {
+	statuses := []string{}
+	for _, t := range p.Telemetry {
+		if t.Type == StatusTelemetryType {
+			statuses = append(statuses, t.Status)
+		}
+	}
+	return statuses
+}
+
+// StatusValue returns the value of the first 'KEY=VALUE' status telemetry string with the given key
+func (p *DecodedPacket) StatusValue(key string) (string, bool) {
+	prefix := key + "="
+	for _, status := range p.Statuses() {
+		if strings.HasPrefix(status, prefix) {
+			return strings.TrimPrefix(status, prefix), true
+		}
+	}
+	return "", false
+}
+
+// Temperature returns the first decoded temperature telemetry value (in celsius)
+func (p *DecodedPacket) Temperature() (float32, bool) {
+	for _, t := range p.Telemetry {
+		if t.GroupID == TelemetryGroupID && t.Type == TemperatureTelemetryType {
+			return t.Celsius, true
+		}
+	}
+	return 0, false
+}
+
+// UtilState returns the utility state name ('unplugged', 'off', 'idle', or 'active') of the decoded packet
+func (p *DecodedPacket) UtilState() (string, bool) {
+	for _, status := range p.Statuses() {
+		if name, ok := UtilStateNames[status]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// BatteryCharge returns the decoded battery charge percentage remaining
+func (p *DecodedPacket) BatteryCharge() int {
+	return int((p.Battery.Percent>>3)&0x0F) * 10
+}
+
+// BatteryTolerance returns the decoded battery prediction tolerance percentage
+func (p *DecodedPacket) BatteryTolerance() int {
+	return int(p.Battery.Percent&0x07) * 10
+}
+
+func dumpStaticInfo(w io.Writer, packet *ParsedPacket) {
 	// dump the static packet info
-	fmt.Printf("  - UDP Version = %d\n", packet.EmanateHeader.UDPVersion)
-	fmt.Printf("  - Tag MAC = %s\n", util.MACBytesToString(packet.EmanateHeader.TagMACAddr))
-	fmt.Printf("  - AP MAC = %s\n", util.MACBytesToString(packet.EmanateHeader.APMACAddr))
-	fmt.Printf("  - Sequence = %d\n", packet.EmanateHeader.Sequence)
-	fmt.Printf("  - Header\n")
-	fmt.Printf("    - Protocol Version = %d\n", packet.Header.Version)
-	fmt.Printf("    - Transmit Power = %d\n", packet.Header.Power)
-	fmt.Printf("    - Wifi Channel = %d\n", packet.Header.Channel)
-	fmt.Printf("    - Burst Length = %d\n", packet.Header.Burst)
-	fmt.Printf("  - System Group\n")
-	fmt.Printf("    - ID = %d\n", packet.System.ID)
-	fmt.Printf("    - Length = %d\n", packet.System.Length)
-	fmt.Printf("    - Product Type = %d\n", packet.System.ProductType)
-	fmt.Printf("  - Battery Group\n")
-	fmt.Printf("    - ID = %d\n", packet.Battery.ID)
-	fmt.Printf("    - Length = %d\n", packet.Battery.Length)
-	fmt.Printf("    - Tolerance = %d %%\n", (packet.Battery.Percent&0x07)*10)
-	fmt.Printf("    - Charge = %d %%\n", ((packet.Battery.Percent>>3)&0x0F)*10)
-	fmt.Printf("    - Days Remaining = %d\n", packet.Battery.Days)
-	fmt.Printf("    - Age = %d days\n", packet.Battery.Age)
-}
-
-func dumpTelemetryInfo(data []byte) error {
-	//fmt.Printf("TELEMETRY: data len = %d, tel data = %v\n", len(data), data)
+	fmt.Fprintf(w, "  - UDP Version = %d\n", packet.EmanateHeader.UDPVersion)
+	fmt.Fprintf(w, "  - Tag MAC = %s\n", util.MACBytesToString(packet.EmanateHeader.TagMACAddr))
+	fmt.Fprintf(w, "  - AP MAC = %s\n", util.MACBytesToString(packet.EmanateHeader.APMACAddr))
+	fmt.Fprintf(w, "  - Sequence = %d\n", packet.EmanateHeader.Sequence)
+	fmt.Fprintf(w, "  - Header\n")
+	fmt.Fprintf(w, "    - Protocol Version = %d\n", packet.Header.Version)
+	fmt.Fprintf(w, "    - Transmit Power = %d\n", packet.Header.Power)
+	fmt.Fprintf(w, "    - Wifi Channel = %d\n", packet.Header.Channel)
+	fmt.Fprintf(w, "    - Burst Length = %d\n", packet.Header.Burst)
+	fmt.Fprintf(w, "  - System Group\n")
+	fmt.Fprintf(w, "    - ID = %d\n", packet.System.ID)
+	fmt.Fprintf(w, "    - Length = %d\n", packet.System.Length)
+	fmt.Fprintf(w, "    - Product Type = %d\n", packet.System.ProductType)
+	fmt.Fprintf(w, "  - Battery Group\n")
+	fmt.Fprintf(w, "    - ID = %d\n", packet.Battery.ID)
+	fmt.Fprintf(w, "    - Length = %d\n", packet.Battery.Length)
+	fmt.Fprintf(w, "    - Tolerance = %d %%\n", (packet.Battery.Percent&0x07)*10)
+	fmt.Fprintf(w, "    - Charge = %d %%\n", ((packet.Battery.Percent>>3)&0x0F)*10)
+	fmt.Fprintf(w, "    - Days Remaining = %d\n", packet.Battery.Days)
+	fmt.Fprintf(w, "    - Age = %d days\n", packet.Battery.Age)
+}
+
+func dumpTelemetryInfo(w io.Writer, telemetry []DecodedTelemetry) {
+	// iterate through all of the decoded telemetry entries
+	for _, t := range telemetry {
+		// if the group is not a telemetry group
+		if t.GroupID != TelemetryGroupID {
+			fmt.Fprintf(w, "  - Unknown Group\n")
+			fmt.Fprintf(w, "    - Group ID = %d\n", t.GroupID)
+			fmt.Fprintf(w, "    - Group Length = %d\n", t.GroupLength)
+			continue
+		}
+
+		switch t.Type {
+		case TemperatureTelemetryType:
+			fmt.Fprintf(w, "  - Temperature Group\n")
+			fmt.Fprintf(w, "    - Group ID = %d\n", t.GroupID)
+			fmt.Fprintf(w, "    - Group Length = %d\n", t.GroupLength)
+			fmt.Fprintf(w, "    - Type = %d\n", t.Type)
+			fmt.Fprintf(w, "    - Temperature = %.2f C\n", t.Celsius)
+
+		case StatusTelemetryType:
+			fmt.Fprintf(w, "  - Status Group\n")
+			fmt.Fprintf(w, "    - Group ID = %d\n", t.GroupID)
+			fmt.Fprintf(w, "    - Group Length = %d\n", t.GroupLength)
+			fmt.Fprintf(w, "    - Type = %d\n", t.Type)
+			fmt.Fprintf(w, "    - Status Length = %d\n", t.StatusLength)
+			fmt.Fprintf(w, "    - Status = '%s'\n", t.Status)
+
+		default:
+			fmt.Fprintf(w, "  - Unknown Telemetry Group\n")
+			fmt.Fprintf(w, "    - Group ID = %d\n", t.GroupID)
+			fmt.Fprintf(w, "    - Group Length = %d\n", t.GroupLength)
+			fmt.Fprintf(w, "    - Type = %d\n", t.Type)
+		}
+	}
+}
+
+func decodeTelemetry(data []byte) ([]DecodedTelemetry, error) {
+	telemetry := []DecodedTelemetry{}
 	cursor := 0
 
 	// iterate through all of the telemetry entries
 	for cursor < len(data) {
-		// if not enough data is available (groupID + groupLength + telemetryType)
-		if len(data) < 3 {
-			// log the error and return now
-			msg := "Truncated of malformed Emanate CCX UDP packet"
-			fmt.Printf("\nERROR: %s\n\n", msg)
-			return fmt.Errorf(msg)
+		// if not enough data is available (groupID + groupLength)
+		if len(data)-cursor < 2 {
+			// return the error now
+			return telemetry, decodeError(ErrTruncatedGroup, TelemetryDataOffset+cursor)
 		}
 
-		// get the group id
+		// get the group id and group length
 		groupID := data[cursor]
-		cursor = cursor + 1
-
-		// determine the group id
-		switch groupID {
-		// telemetry group id (3)
-		case TelemetryGroupID:
-			// get the group length
-			groupLength := int(data[cursor])
-			cursor = cursor + 1
-
-			// get the telemetry type
-			telemetryType := data[cursor]
-			cursor = cursor + 1
-
-			// determine the telemetry type
-			switch telemetryType {
-			case TemperatureTelemetryType:
-				// if not enough data is available (tempC)
-				if len(data)-cursor < 4 {
-					// log the error and return now
-					msg := "Truncated of malformed temperature group in Emanate CCX UDP packet"
-					fmt.Printf("\nERROR: %s\n\n", msg)
-					return fmt.Errorf(msg)
-				}
-
-				tempC := util.Float32FromBytes(data[cursor:])
-				cursor = cursor + 4
-
-				fmt.Printf("  - Temperature Group\n")
-				fmt.Printf("    - Group ID = %d\n", groupID)
-				fmt.Printf("    - Group Length = %d\n", groupLength)
-				fmt.Printf("    - Type = %d\n", telemetryType)
-				fmt.Printf("    - Temperature = %.2f C\n", tempC)
-
-			case StatusTelemetryType:
-				// if not enough data is available (tempC)
-				if len(data)-cursor < 1 {
-					// log the error and return now
-					msg := "Truncated of malformed status telemetry group in Emanate CCX UDP packet"
-					fmt.Printf("\nERROR: %s\n\n", msg)
-					return fmt.Errorf(msg)
-				}
-
-				statusLength := int(data[cursor])
-				cursor = cursor + 1
-
-				// if not enough data is available (tempC)
-				if len(data)-cursor < statusLength {
-					// log the error and return now
-					msg := "Truncated of malformed status telemetry string in Emanate CCX UDP packet"
-					fmt.Printf("\nERROR: %s\n\n", msg)
-					return fmt.Errorf(msg)
-				}
-
-				// extract the utf-16 status string and advance the cursor
-				statusUTF16 := string(data[cursor : cursor+statusLength])
-				cursor = cursor + statusLength
-
-				// convert the utf-16 string into an ascii string
-				statusASCII := util.UTF16ToASCII(statusUTF16)
-
-				fmt.Printf("  - Status Group\n")
-				fmt.Printf("    - Group ID = %d\n", groupID)
-				fmt.Printf("    - Group Length = %d\n", groupLength)
-				fmt.Printf("    - Type = %d\n", telemetryType)
-				fmt.Printf("    - Status Length = %d\n", statusLength)
-				fmt.Printf("    - Status = '%s'\n", statusASCII)
-
-			default:
-				fmt.Printf("  TELEMETRY TYPE ERROR! (%d)", telemetryType)
+		groupLength := data[cursor+1]
+		groupOffset := TelemetryDataOffset + cursor
+		cursor = cursor + 2
+
+		// if not enough data is available (group payload)
+		if len(data)-cursor < int(groupLength) {
+			// return the error now
+			return telemetry, decodeError(ErrTruncatedGroup, groupOffset)
+		}
+
+		// get the group payload and advance the cursor to the next group
+		payload := data[cursor : cursor+int(groupLength)]
+		cursor = cursor + int(groupLength)
+
+		// if the group is not a telemetry group (3) or has no telemetry type
+		if groupID != TelemetryGroupID || len(payload) < 1 {
+			// skip the unknown group
+			telemetry = append(telemetry, DecodedTelemetry{
+				GroupID:     groupID,
+				GroupLength: groupLength,
+			})
+			continue
+		}
+
+		// get the telemetry type
+		telemetryType := payload[0]
+		payload = payload[1:]
+
+		// determine the telemetry type
+		switch telemetryType {
+		case TemperatureTelemetryType:
+			// if not enough data is available (tempC)
+			if len(payload) < 4 {
+				// return the error now
+				return telemetry, decodeError(ErrMalformedTemperature, groupOffset)
 			}
 
+			telemetry = append(telemetry, DecodedTelemetry{
+				GroupID:     groupID,
+				GroupLength: groupLength,
+				Type:        telemetryType,
+				Celsius:     util.Float32FromBytes(payload[:4]),
+			})
+
+		case StatusTelemetryType:
+			// if not enough data is available (status length)
+			if len(payload) < 1 {
+				// return the error now
+				return telemetry, decodeError(ErrMalformedStatus, groupOffset)
+			}
+
+			// if not enough data is available (status string)
+			statusLength := int(payload[0])
+			if len(payload)-1 < statusLength {
+				// return the error now
+				return telemetry, decodeError(ErrMalformedStatus, groupOffset)
+			}
+
+			// extract the utf-16 status string
+			statusUTF16 := string(payload[1 : 1+statusLength])
+
+			telemetry = append(telemetry, DecodedTelemetry{
+				GroupID:      groupID,
+				GroupLength:  groupLength,
+				Type:         telemetryType,
+				StatusLength: uint8(statusLength),
+				Status:       util.UTF16ToASCII(statusUTF16),
+			})
+
 		default:
-			fmt.Printf("  TELEMETRY GROUP ID ERROR! (%d)", groupID)
+			// skip the unknown telemetry type
+			telemetry = append(telemetry, DecodedTelemetry{
+				GroupID:     groupID,
+				GroupLength: groupLength,
+				Type:        telemetryType,
+			})
 		}
 	}
 
-	return nil
+	return telemetry, nil
+}
+
+func decodeError(err error, offset int) error {
+	return fmt.Errorf("%w in Emanate CCX UDP packet (offset %d)", err, offset)
 }
@@ -0,0 +1,294 @@
+package ccx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// update regenerates the golden files instead of comparing against them
+var update = flag.Bool("update", false, "update the golden test vector files")
+
+// goldenDir is the directory of the golden binary packets and expected decoded output
+const goldenDir = "testdata/golden"
+
+// goldenPackets defines the packets used to generate each golden test vector
+var goldenPackets = []struct {
+	name  string
+	build func(t *testing.T) *Packet
+}{
+	{
+		name: "default",
+		build: func(t *testing.T) *Packet {
+			return NewPacket()
+		},
+	},
+	{
+		name:  "kitchen_sink",
+		build: kitchenSinkPacket,
+	},
+	{
+		name: "temperature_only",
+		build: func(t *testing.T) *Packet {
+			p := NewPacket()
+			mustSucceed(t, p.SetTagMACAddress("00:11:22:33:44:55"))
+			mustSucceed(t, p.SetAPMACAddress("AA:BB:CC:DD:EE:FF"))
+			p.SetSequenceNumber(65535)
+			mustSucceed(t, p.SetTemperature(-20.5))
+			return p
+		},
+	},
+	{
+		name: "battery_and_status",
+		build: func(t *testing.T) *Packet {
+			p := NewPacket()
+			p.SetProductType(42)
+			p.SetBatteryInfo(&BatteryInfo{
+				TolerancePercent: 20,
+				PercentRemaining: 100,
+				DaysRemaining:    365,
+				AgeDays:          1000,
+			})
+			mustSucceed(t, p.SetUtilState(UtilStatePluggedInActive))
+			mustSucceed(t, p.SetDoorOpenPercent(100))
+			return p
+		},
+	},
+	{
+		name: "send_timestamp",
+		build: func(t *testing.T) *Packet {
+			p := NewPacket()
+			mustSucceed(t, p.WriteStatusTelemetry(NewSendTimestampTelemetry(time.Unix(1500000000, 123456789))))
+			return p
+		},
+	},
+}
+
+// kitchenSinkPacket builds the same packet as the sender's '--all' option using its default values
+func kitchenSinkPacket(t *testing.T) *Packet {
+	p := NewPacket()
+	p.SetBurstLength(1)
+	mustSucceed(t, p.SetUtilState(UtilStateUnplugged))
+	p.SetBatteryInfo(&BatteryInfo{
+		TolerancePercent: 0,
+		PercentRemaining: 80,
+		DaysRemaining:    100,
+		AgeDays:          10,
+	})
+	mustSucceed(t, p.SetTemperature(12.34))
+	mustSucceed(t, p.SetDoorOpenPercent(22))
+	mustSucceed(t, p.SetHighPowerPercent(33))
+	mustSucceed(t, p.SetButtonPressed())
+	mustSucceed(t, p.SetProbeUnplugged())
+	mustSucceed(t, p.SetProbeInvalidValue())
+	return p
+}
+
+func mustSucceed(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// withBytes returns a copy of the given packet bytes with the given bytes appended
+func withBytes(data []byte, b ...byte) []byte {
+	return append(append([]byte{}, data...), b...)
+}
+
+// dumpString returns the decoded packet dump (and decode error) as a string
+func dumpString(data []byte) string {
+	buf := &bytes.Buffer{}
+	packet, err := Decode(data)
+	if packet != nil {
+		Fdump(buf, packet)
+	}
+	if err != nil {
+		buf.WriteString("ERROR: " + err.Error() + "\n")
+	}
+	return buf.String()
+}
+
+// goldenFile reads the given golden file (or writes it when updating)
+func goldenFile(t *testing.T, path string, actual []byte) []byte {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("cannot write golden file '%s': %v", path, err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file '%s' (run 'go test -update' to create it): %v", path, err)
+	}
+	return expected
+}
+
+func TestPackGolden(t *testing.T) {
+	for _, gp := range goldenPackets {
+		t.Run(gp.name, func(t *testing.T) {
+			data, err := gp.build(t).Pack()
+			mustSucceed(t, err)
+
+			expected := goldenFile(t, filepath.Join(goldenDir, gp.name+".bin"), data)
+			if !bytes.Equal(data, expected) {
+				t.Errorf("packed bytes differ from golden packet\n got: %x\nwant: %x", data, expected)
+			}
+		})
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(goldenDir, "*.bin"))
+	mustSucceed(t, err)
+	if len(paths) == 0 {
+		t.Fatalf("no golden packets found in '%s'", goldenDir)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".bin")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			mustSucceed(t, err)
+
+			actual := dumpString(data)
+			expected := goldenFile(t, filepath.Join(goldenDir, name+".golden"), []byte(actual))
+			if actual != string(expected) {
+				t.Errorf("decoded output differs from golden output\n got:\n%s\nwant:\n%s", actual, expected)
+			}
+		})
+	}
+}
+
+func TestParsedPacketSize(t *testing.T) {
+	if n := binary.Size(ParsedPacket{}); n != TelemetryDataOffset {
+		t.Errorf("static packet size = %d, want telemetry data offset %d", n, TelemetryDataOffset)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	base, err := NewPacket().Pack()
+	mustSucceed(t, err)
+	status := NewStatusTelemetry(ButtonPressedTelemetry)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		err       error
+		telemetry int
+	}{
+		{"empty", []byte{}, ErrTruncatedPacket, 0},
+		{"truncated static fields", base[:TelemetryDataOffset-1], ErrTruncatedPacket, 0},
+		{"group id only", withBytes(base, TelemetryGroupID), ErrTruncatedGroup, 0},
+		{"group id and length only", withBytes(base, TelemetryGroupID, 5), ErrTruncatedGroup, 0},
+		{"empty temperature group", withBytes(base, TelemetryGroupID, 1, TemperatureTelemetryType), ErrMalformedTemperature, 0},
+		{"empty status group", withBytes(base, TelemetryGroupID, 1, StatusTelemetryType), ErrMalformedStatus, 0},
+		{"status length too long", withBytes(base, TelemetryGroupID, 4, StatusTelemetryType, 200, 0, 'X'), ErrMalformedStatus, 0},
+		{"unknown group", withBytes(base, 7, 2, 0xAA, 0xBB), nil, 1},
+		{"unknown telemetry type", withBytes(base, TelemetryGroupID, 2, 99, 0xAA), nil, 1},
+		{"empty telemetry group", withBytes(base, TelemetryGroupID, 0), nil, 1},
+		{"odd utf-16 status", withBytes(base, status.ID, 5, status.Type, 3, 0x00, 'B', 0x00), nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := Decode(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err == ErrTruncatedPacket {
+				if packet != nil {
+					t.Errorf("packet = %+v, want nil", packet)
+				}
+				return
+			}
+			if len(packet.Telemetry) != tt.telemetry {
+				t.Errorf("telemetry entries = %d, want %d", len(packet.Telemetry), tt.telemetry)
+			}
+		})
+	}
+}
+
+func TestKitchenSinkLength(t *testing.T) {
+	data, err := kitchenSinkPacket(t).Pack()
+	mustSucceed(t, err)
+	if len(data) != 337 {
+		t.Errorf("kitchen-sink packet length = %d, want 337", len(data))
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	p := kitchenSinkPacket(t)
+	data, err := p.Pack()
+	mustSucceed(t, err)
+
+	decoded, err := Decode(data)
+	mustSucceed(t, err)
+
+	if decoded.EmanateHeader != p.EmanateHeader {
+		t.Errorf("emanate header = %+v, want %+v", decoded.EmanateHeader, p.EmanateHeader)
+	}
+	if decoded.Header != p.Header || decoded.System != p.System || decoded.Battery != p.Battery {
+		t.Errorf("static groups = %+v, want %+v", decoded.ParsedPacket, p)
+	}
+	if decoded.TagMAC() != "11:22:33:44:55:66" || decoded.APMAC() != "66:55:44:33:22:11" {
+		t.Errorf("mac-addresses = %s / %s", decoded.TagMAC(), decoded.APMAC())
+	}
+
+	statuses := decoded.Statuses()
+	expected := []string{
+		UtilStateUnplugged,
+		"DOOR_OPEN_PERCENT=22",
+		"HIGH_POWER_MODE_PERCENT=33",
+		ButtonPressedTelemetry,
+		ProbeUnpluggedTelemetry,
+		ProbeInvalidValueTelemetry,
+	}
+	if strings.Join(statuses, "|") != strings.Join(expected, "|") {
+		t.Errorf("statuses = %q, want %q", statuses, expected)
+	}
+}
+
+func TestSendTimestampRoundTrip(t *testing.T) {
+	data, err := NewPacket().Pack()
+	mustSucceed(t, err)
+
+	ts := time.Unix(1700000000, 42)
+	decoded, err := Decode(AppendSendTimestamp(data, ts))
+	mustSucceed(t, err)
+
+	actual, ok := decoded.SendTimestamp()
+	if !ok || !actual.Equal(ts) {
+		t.Errorf("send timestamp = %v (%t), want %v", actual, ok, ts)
+	}
+}
+
+// FuzzDecode checks that decoding (and dumping) any input never panics
+func FuzzDecode(f *testing.F) {
+	// seed the corpus with every golden packet
+	paths, _ := filepath.Glob(filepath.Join(goldenDir, "*.bin"))
+	for _, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			f.Add(data)
+		}
+	}
+
+	// seed the corpus with the packets that crashed earlier decoder versions
+	base, _ := NewPacket().Pack()
+	f.Add(withBytes(base, TelemetryGroupID))
+	f.Add(withBytes(base, TelemetryGroupID, 5))
+	f.Add(withBytes(base, TelemetryGroupID, 0xFF, StatusTelemetryType, 0xFF))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet, _ := Decode(data)
+		if packet != nil {
+			Fdump(&bytes.Buffer{}, packet)
+		}
+	})
+}
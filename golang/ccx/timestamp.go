@@ -0,0 +1,68 @@
+package ccx
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SendTimestampPrefix is the status string prefix of the test-only send timestamp telemetry.
+// This is NOT part of the Emanate PowerPath protocol and is only sent by the udp sender tool
+// to allow the end-to-end latency to be measured by the udp receiver tool.
+const SendTimestampPrefix = "TEST_SEND_TS_NS="
+
+// NewSendTimestampTelemetry creates a new test-only send timestamp status telemetry instance
+func NewSendTimestampTelemetry(ts time.Time) StatusTelemetry {
+	// build the fixed-width telemetry status string (so the packet length never changes)
+	status := fmt.Sprintf("%s%019d", SendTimestampPrefix, ts.UnixNano())
+
+	// create and return the status telemetry instance
+	return NewStatusTelemetry(status)
+}
+
+// AppendSendTimestamp returns a copy of the given packed packet bytes with the test-only
+// send timestamp status telemetry appended to the end of the telemetry group data
+func AppendSendTimestamp(data []byte, ts time.Time) []byte {
+	// create the timestamp status telemetry instance
+	t := NewSendTimestampTelemetry(ts)
+
+	// copy the packed packet and append the status telemetry fields
+	buf := bytes.NewBuffer(append([]byte{}, data...))
+	buf.Write([]byte{t.ID, t.Length, t.Type, t.StatusLength})
+	buf.WriteString(t.Status)
+
+	// return the assembled bytes
+	return buf.Bytes()
+}
+
+// ParseSendTimestamp parses the given ascii status string as a test-only send timestamp
+func ParseSendTimestamp(status string) (time.Time, bool) {
+	// if the status string is not a send timestamp
+	if !strings.HasPrefix(status, SendTimestampPrefix) {
+		return time.Time{}, false
+	}
+
+	// parse the unix nanoseconds timestamp value
+	ns, err := strconv.ParseInt(strings.TrimPrefix(status, SendTimestampPrefix), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// return the send timestamp
+	return time.Unix(0, ns), true
+}
+
+// SendTimestamp returns the test-only send timestamp of the decoded packet (if included)
+func (p *DecodedPacket) SendTimestamp() (time.Time, bool) {
+	// find the first send timestamp status string
+	for _, status := range p.Statuses() {
+		if ts, ok := ParseSendTimestamp(status); ok {
+			return ts, true
+		}
+	}
+
+	// the packet does not include a send timestamp
+	return time.Time{}, false
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/auth"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/proxy"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	// add some console output white-space
+	fmt.Println("")
+
+	// create the cli app
+	app := cli.NewApp()
+	app.Version = "v1.0.2"
+	app.Name = "emanate_udp_proxy"
+	app.HelpName = "emanate_udp_proxy"
+	app.Usage = "Emanate PowerPath UDP CCX packet forwarding and fan-out proxy"
+	app.UsageText = "emanate_udp_proxy --port <LISTENING-PORT> --dest <HOST:PORT> [--dest <HOST:PORT> ...] [options]"
+
+	// define the cli flags
+	app.Flags = []cli.Flag{
+		cli.IntFlag{
+			Name:  "port",
+			Value: 9999,
+			Usage: "local udp receiver port number",
+		},
+		cli.StringSliceFlag{
+			Name:  "dest",
+			Usage: "'host:port' destination that every received datagram is relayed to (repeatable)",
+		},
+		cli.StringFlag{
+			Name:  "dest-transport",
+			Value: "udp",
+			Usage: "relays the datagrams over 'udp', or as length prefixed packets over 'tcp' or 'tls' connections",
+		},
+		cli.StringFlag{
+			Name:  "dest-tls-ca-file",
+			Value: "",
+			Usage: "pem ca file to verify the tls destination certificates (the system CAs if empty)",
+		},
+		cli.StringFlag{
+			Name:  "dest-tls-cert-file",
+			Value: "",
+			Usage: "pem tls client certificate file, if the destinations require one",
+		},
+		cli.StringFlag{
+			Name:  "dest-tls-key-file",
+			Value: "",
+			Usage: "pem tls client private key file",
+		},
+		cli.StringSliceFlag{
+			Name:  "tag-mac",
+			Usage: "relays only the packets of the given tag mac-address (repeatable, every tag if not given)",
+		},
+		cli.IntSliceFlag{
+			Name:  "product-type",
+			Usage: "relays only the packets of the given product type (repeatable, every product type if not given)",
+		},
+		cli.StringFlag{
+			Name:  "rewrite-ap-mac",
+			Value: "",
+			Usage: "replaces the AP mac-address of every relayed packet (unchanged if empty)",
+		},
+		cli.StringFlag{
+			Name:  "auth-keys",
+			Value: "",
+			Usage: "json file of the hmac-sha256 packet authentication keys (drops every unauthenticated packet, disabled if empty)",
+		},
+		cli.StringFlag{
+			Name:  "auth-key",
+			Value: "",
+			Usage: "hex hmac-sha256 key re-signing the verified packets (requires --auth-keys, the packets keep their trailer if empty)",
+		},
+		cli.IntFlag{
+			Name:  "auth-key-id",
+			Value: 1,
+			Usage: "key id of the authentication key (0-255)",
+		},
+		cli.IntFlag{
+			Name:  "stats-interval",
+			Value: 60,
+			Usage: "interval seconds between the per-destination stats reports (0 disables)",
+		},
+	}
+
+	// define the cli execution handler
+	app.Action = func(c *cli.Context) error {
+		// convert the product type filters
+		productTypes := []uint16{}
+		for _, pt := range c.IntSlice("product-type") {
+			productTypes = append(productTypes, uint16(pt))
+		}
+
+		// validate the destination transport
+		transport, err := udp.ParseTransport(c.String("dest-transport"))
+		if err != nil {
+			fmt.Printf("Error creating proxy (error = '%v')\n\n", err)
+			os.Exit(1)
+		}
+		var tlsFiles *util.TLSFiles
+		if transport == udp.TransportTLS {
+			tlsFiles = &util.TLSFiles{
+				CAFile:   c.String("dest-tls-ca-file"),
+				CertFile: c.String("dest-tls-cert-file"),
+				KeyFile:  c.String("dest-tls-key-file"),
+			}
+		}
+
+		// load the packet authentication keys if enabled
+		var keyring *auth.Keyring
+		if path := c.String("auth-keys"); path != "" {
+			keyring, err = auth.LoadKeyring(path)
+			if err != nil {
+				fmt.Printf("Error loading authentication keys '%s' (error = '%v')\n\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		// parse the re-signing authentication key if given
+		var authKey []byte
+		if hexKey := c.String("auth-key"); hexKey != "" {
+			authKey, err = auth.ParseKey(hexKey)
+			if err == nil && (c.Int("auth-key-id") < 0 || c.Int("auth-key-id") > 255) {
+				err = fmt.Errorf("authentication key id must be between 0 and 255")
+			}
+			if err != nil {
+				fmt.Printf("Error creating proxy (error = '%v')\n\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// create the proxy
+		p, err := proxy.NewProxy(&proxy.ProxyOptions{
+			Destinations: c.StringSlice("dest"),
+			Transport:    transport,
+			TLS:          tlsFiles,
+			TagMACs:      c.StringSlice("tag-mac"),
+			ProductTypes: productTypes,
+			RewriteAPMAC: c.String("rewrite-ap-mac"),
+			Keyring:      keyring,
+			AuthKey:      authKey,
+			AuthKeyID:    uint8(c.Int("auth-key-id")),
+		})
+		if err != nil {
+			fmt.Printf("Error creating proxy (error = '%v')\n\n", err)
+			os.Exit(1)
+		}
+
+		// log the proxy destinations
+		for _, ds := range p.Stats().Destinations {
+			fmt.Printf("Relaying UDP datagrams to '%s' (%s)\n", ds.Destination, transport)
+		}
+
+		// if the periodic stats report is enabled
+		if interval := c.Int("stats-interval"); interval > 0 {
+			go func() {
+				for range time.Tick(time.Duration(interval) * time.Second) {
+					p.Dump()
+				}
+			}()
+		}
+
+		// create a udp receiver instance relaying every datagram
+		receiver := udp.NewReceiver(&udp.ReceiverOptions{
+			Port: c.Int("port"),
+		})
+		receiver.DataHandler(p.Handle)
+
+		// stop receiving packets once interrupted or terminated
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			s := <-signals
+			fmt.Printf("\nReceived '%v' signal, shutting down\n", s)
+			receiver.Close()
+		}()
+
+		// start receiving packets, then relay the queued datagrams and close the destinations
+		receiver.Run()
+		p.Close()
+
+		return nil
+	}
+
+	// start the cli app
+	app.Run(os.Args)
+}
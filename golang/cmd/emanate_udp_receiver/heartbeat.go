@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/heartbeat"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/output"
+	"github.com/urfave/cli"
+)
+
+// heartbeatFlags defines the cli flags of the missing tag detection
+var heartbeatFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "heartbeat-misses",
+		Value: 0,
+		Usage: "number of missed reporting intervals before a 'tag_silent' event is published (0 disables)",
+	},
+	cli.IntFlag{
+		Name:  "heartbeat-interval",
+		Value: 0,
+		Usage: "expected reporting interval seconds of every tag (0 learns the interval of each tag from the traffic)",
+	},
+	cli.StringSliceFlag{
+		Name:  "heartbeat-tag-interval",
+		Usage: "expected 'MAC=SECONDS' reporting interval of a single tag (repeatable)",
+	},
+}
+
+// createMonitor creates the missing tag monitor if enabled by the cli flags, publishing the silent and
+// recovered tag events to every output
+func createMonitor(c *cli.Context, outputs []output.Output) (*heartbeat.Monitor, error) {
+	// if the missing tag detection is disabled
+	misses := c.Int("heartbeat-misses")
+	if misses <= 0 {
+		return nil, nil
+	}
+
+	// parse the per-tag reporting intervals
+	intervals := map[string]time.Duration{}
+	for _, v := range c.StringSlice("heartbeat-tag-interval") {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid heartbeat tag interval '%s' (expected 'MAC=SECONDS')", v)
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("Invalid heartbeat tag interval '%s' (expected 'MAC=SECONDS')", v)
+		}
+		intervals[strings.TrimSpace(parts[0])] = time.Duration(seconds) * time.Second
+	}
+
+	// create the monitor
+	return heartbeat.NewMonitor(&heartbeat.MonitorOptions{
+		Interval:  time.Duration(c.Int("heartbeat-interval")) * time.Second,
+		Intervals: intervals,
+		Misses:    misses,
+		Handler: func(e *heartbeat.Event) {
+			fmt.Printf("\nTAG %s: '%s' (last seen %s, interval %gs)\n\n",
+				strings.ToUpper(e.Type), e.TagMAC, e.LastSeen.Format(time.RFC3339), e.IntervalSeconds)
+			publish(outputs, &output.Message{
+				Type:   e.Type,
+				TS:     e.TS,
+				TagMAC: e.TagMAC,
+				Event:  e,
+			})
+		},
+	}), nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+// httpFlags defines the cli flags of the receiver http server
+var httpFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "http-listen",
+		Value: "",
+		Usage: "local 'host:port' address of the http server providing the '/metrics', '/tags', '/locations', '/utilization', and '/alerts' endpoints (disabled if empty)",
+	},
+}
+
+// startHTTPServer starts serving the given http handler if enabled by the cli flags
+func startHTTPServer(c *cli.Context, handler http.Handler) {
+	// if the http server is not enabled
+	addr := c.String("http-listen")
+	if addr == "" {
+		return
+	}
+
+	fmt.Printf("Starting HTTP server listening on '%s'\n", addr)
+
+	// serve the http requests until the process exits
+	go func() {
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			// log the error and exit the process now
+			fmt.Printf("Error starting HTTP server listening on '%s' (error = '%v')\n\n", addr, err)
+			os.Exit(1)
+		}
+	}()
+}
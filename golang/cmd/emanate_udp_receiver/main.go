@@ -3,11 +3,24 @@ package main
 import (
 	"encoding/hex"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
+	"github.com/EmanateWireless/emanate-udp-tools/golang/alert"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/auth"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/burst"
 	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/dedup"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/filter"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/history"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/latency"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/location"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/metrics"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/output"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/state"
 	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/utilization"
 	"github.com/urfave/cli"
 )
 
@@ -30,17 +43,336 @@ func main() {
 			Value: 9999,
 			Usage: "local udp receiver port number",
 		},
+		cli.IntFlag{
+			Name:  "read-buffer",
+			Value: 0,
+			Usage: "kernel receive buffer bytes of the udp sockets (SO_RCVBUF, the kernel default if 0)",
+		},
+		cli.IntFlag{
+			Name:  "listeners",
+			Value: 1,
+			Usage: "number of udp sockets listening on the port with SO_REUSEPORT, each read by its own goroutine",
+		},
+		cli.IntFlag{
+			Name:  "max-datagram-size",
+			Value: udp.DefaultMaxDatagramSize,
+			Usage: "largest accepted datagram bytes (the larger datagrams are dropped as truncated)",
+		},
+		cli.IntFlag{
+			Name:  "batch-size",
+			Value: 32,
+			Usage: "number of udp datagrams read per recvmmsg syscall on linux (1 reads a single datagram per syscall)",
+		},
+		cli.StringFlag{
+			Name:  "input",
+			Value: "",
+			Usage: "replays the packets of a pcap or hex lines file ('-' for stdin) instead of listening, then exits",
+		},
+		cli.IntFlag{
+			Name:  "input-port",
+			Value: 0,
+			Usage: "replays only the pcap udp datagrams sent to the given port (any port if 0)",
+		},
+		cli.BoolFlag{
+			Name:  "input-pace",
+			Usage: "replays the pcap packets with their captured spacing (as fast as possible otherwise)",
+		},
+		cli.StringSliceFlag{
+			Name:  "allow",
+			Usage: "accepts only the datagrams from the given cidr network or ip-address (repeatable, any source if not given)",
+		},
+		cli.StringSliceFlag{
+			Name:  "deny",
+			Usage: "drops the datagrams from the given cidr network or ip-address (repeatable)",
+		},
+		cli.Float64Flag{
+			Name:  "source-rate",
+			Value: 0,
+			Usage: "maximum datagrams per second accepted from each source ip-address (0 is unlimited)",
+		},
+		cli.IntFlag{
+			Name:  "source-burst",
+			Value: 0,
+			Usage: "maximum burst of datagrams accepted from each source ip-address (0 is one second of datagrams)",
+		},
+		cli.Float64Flag{
+			Name:  "tag-rate",
+			Value: 0,
+			Usage: "maximum datagrams per second accepted from each tag mac-address (0 is unlimited)",
+		},
+		cli.IntFlag{
+			Name:  "tag-burst",
+			Value: 0,
+			Usage: "maximum burst of datagrams accepted from each tag mac-address (0 is one second of datagrams)",
+		},
+		cli.StringFlag{
+			Name:  "auth-keys",
+			Value: "",
+			Usage: "json file of the hmac-sha256 packet authentication keys (rejects every unauthenticated packet, disabled if empty)",
+		},
+		cli.IntFlag{
+			Name:  "auth-replay-window",
+			Value: auth.DefaultReplayWindow,
+			Usage: "number of sequence numbers before the highest authenticated sequence number of a tag still accepted",
+		},
+		cli.IntFlag{
+			Name:  "latency-report-interval",
+			Value: 0,
+			Usage: "interval seconds between test-only latency histogram reports (0 disables)",
+		},
+		cli.StringFlag{
+			Name:  "history-db",
+			Value: "",
+			Usage: "sqlite database file that every decoded packet is written to (disabled if empty)",
+		},
+		cli.IntFlag{
+			Name:  "history-retention-days",
+			Value: 0,
+			Usage: "number of days of packet history kept in the database (0 keeps all history)",
+		},
+		cli.StringFlag{
+			Name:  "alert-rules",
+			Value: "",
+			Usage: "json file of the temperature, door-open, probe error, and battery alert rules (disabled if empty)",
+		},
+		cli.StringFlag{
+			Name:  "filter",
+			Value: "",
+			Usage: "expression selecting the packets that are dumped and processed (e.g. 'tag_mac == \"11:22:33:44:55:66\" && temp > 8')",
+		},
+		cli.StringFlag{
+			Name:  "ap-map",
+			Value: "",
+			Usage: "json file mapping access point mac-addresses to location names (e.g. '{\"AA:BB:CC:DD:EE:FF\": \"3W\"}')",
+		},
+		cli.IntFlag{
+			Name:  "burst-window-ms",
+			Value: 0,
+			Usage: "correlates the copies of each burst forwarded by several access points within the window and publishes a single 'location' event per burst (0 disables)",
+		},
+		cli.BoolFlag{
+			Name:  "utilization-report",
+			Usage: "prints the hourly and daily utility state utilization summaries of every tag",
+		},
+		cli.BoolFlag{
+			Name:  "suppress-duplicates",
+			Usage: "skips publishing duplicate burst copies (same tag and sequence number) to the outputs",
+		},
 	}
+	app.Flags = append(app.Flags, httpFlags...)
+	app.Flags = append(app.Flags, outputFlags...)
+	app.Flags = append(app.Flags, heartbeatFlags...)
+	app.Flags = append(app.Flags, transportFlags...)
+	app.Flags = append(app.Flags, dtlsFlags...)
 
 	// define the cli execution handler
 	app.Action = func(c *cli.Context) error {
+		// create the tcp, tls, and dtls transport options if enabled
+		transport, tlsFiles, err := createTransportOptions(c)
+		if err != nil {
+			fmt.Printf("Error creating transport (error = '%v')\n\n", err)
+			os.Exit(1)
+		}
+		dtlsOptions, err := createDTLSOptions(c)
+		if err != nil {
+			fmt.Printf("Error creating DTLS transport (error = '%v')\n\n", err)
+			os.Exit(1)
+		}
+
 		// create a udp receiver instance
 		receiver := udp.NewReceiver(&udp.ReceiverOptions{
-			Port: c.Int("port"),
+			Port:            c.Int("port"),
+			ReadBuffer:      c.Int("read-buffer"),
+			Listeners:       c.Int("listeners"),
+			MaxDatagramSize: c.Int("max-datagram-size"),
+			BatchSize:       c.Int("batch-size"),
+			Allow:           c.StringSlice("allow"),
+			Deny:            c.StringSlice("deny"),
+			SourceRate:      c.Float64("source-rate"),
+			SourceBurst:     c.Int("source-burst"),
+			TagRate:         c.Float64("tag-rate"),
+			TagBurst:        c.Int("tag-burst"),
+			Transport:       transport,
+			TLS:             tlsFiles,
+			DTLS:            dtlsOptions,
 		})
 
+		// create the decoded packet outputs
+		outputs, err := createOutputs(c)
+		if err != nil {
+			fmt.Printf("Error creating outputs (error = '%v')\n\n", err)
+			os.Exit(1)
+		}
+
+		// load the packet authentication keys if enabled
+		var verifier *auth.Verifier
+		if path := c.String("auth-keys"); path != "" {
+			keyring, err := auth.LoadKeyring(path)
+			if err != nil {
+				fmt.Printf("Error loading authentication keys '%s' (error = '%v')\n\n", path, err)
+				os.Exit(1)
+			}
+			verifier = auth.NewVerifier(&auth.VerifierOptions{
+				Keyring:      keyring,
+				ReplayWindow: c.Int("auth-replay-window"),
+			})
+		}
+
+		// compile the packet filter expression if given
+		var packetFilter *filter.Filter
+		if expr := c.String("filter"); expr != "" {
+			packetFilter, err = filter.Compile(expr)
+			if err != nil {
+				fmt.Printf("Error compiling packet filter (error = '%v')\n\n", err)
+				os.Exit(1)
+			}
+		}
+
+		// create the duplicate burst copy detector and the receiver metrics
+		detector := dedup.NewDetector(&dedup.DetectorOptions{})
+		m := metrics.NewMetrics(&metrics.MetricsOptions{})
+		receiver.DropHandler(m.Dropped)
+		suppressDuplicates := c.Bool("suppress-duplicates")
+
+		// open the packet history database if enabled
+		var hist *history.History
+		if path := c.String("history-db"); path != "" {
+			hist, err = history.NewHistory(&history.HistoryOptions{
+				Path:      path,
+				Retention: time.Duration(c.Int("history-retention-days")) * 24 * time.Hour,
+			})
+			if err != nil {
+				fmt.Printf("Error opening history database '%s' (error = '%v')\n\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		// create the alert rules engine if enabled
+		var alerts *alert.Engine
+		if path := c.String("alert-rules"); path != "" {
+			config, err := alert.LoadConfig(path)
+			if err == nil {
+				alerts, err = alert.NewEngine(config)
+			}
+			if err != nil {
+				fmt.Printf("Error loading alert rules '%s' (error = '%v')\n\n", path, err)
+				os.Exit(1)
+			}
+		}
+
+		// create the missing tag monitor if enabled
+		monitor, err := createMonitor(c, outputs)
+		if err != nil {
+			fmt.Printf("Error creating heartbeat monitor (error = '%v')\n\n", err)
+			os.Exit(1)
+		}
+
+		// create the last-known tag state store
+		store := state.NewStore()
+
+		// create the access point location tracker
+		apMap := map[string]string{}
+		if path := c.String("ap-map"); path != "" {
+			apMap, err = location.LoadAPMap(path)
+			if err != nil {
+				fmt.Printf("Error loading access point map '%s' (error = '%v')\n\n", path, err)
+				os.Exit(1)
+			}
+		}
+		locations := location.NewTracker(&location.TrackerOptions{APMap: apMap})
+
+		// updateLocation tracks the access point location of the tag and publishes the roam events
+		updateLocation := func(tagMAC string, apMAC string, ts time.Time) {
+			if e := locations.Update(tagMAC, apMAC, ts); e != nil {
+				fmt.Printf("\nROAM: %s\n\n", e.Message)
+				publish(outputs, &output.Message{
+					Type:   output.RoamMessageType,
+					TS:     e.TS,
+					TagMAC: e.TagMAC,
+					Event:  e,
+				})
+			}
+		}
+
+		// create the burst correlator if enabled, publishing a single location event per burst
+		var correlator *burst.Correlator
+		if window := c.Int("burst-window-ms"); window > 0 {
+			correlator = burst.NewCorrelator(&burst.CorrelatorOptions{
+				Window: time.Duration(window) * time.Millisecond,
+				Handler: func(b *burst.Burst) {
+					b.Location = locations.Location(b.APMAC)
+					updateLocation(b.TagMAC, b.APMAC, b.TS)
+					publish(outputs, &output.Message{
+						Type:   output.LocationMessageType,
+						TS:     b.TS,
+						TagMAC: b.TagMAC,
+						Event:  b,
+					})
+				},
+			})
+		}
+
+		// create the utilization tracker
+		utilTracker := utilization.NewTracker(&utilization.TrackerOptions{})
+		if c.Bool("utilization-report") {
+			go utilTracker.RunReports()
+		}
+
+		// start the http server
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		mux.Handle("/tags", store.Handler())
+		mux.Handle("/tags/", store.Handler())
+		mux.Handle("/locations", locations.Handler())
+		mux.Handle("/locations/", locations.Handler())
+		mux.Handle("/utilization", utilTracker.Handler())
+		if alerts != nil {
+			mux.Handle("/alerts", alerts.Handler())
+		}
+		startHTTPServer(c, mux)
+
+		// create the test-only latency tracker
+		tracker := latency.NewTracker()
+
+		// if the periodic latency report is enabled
+		if interval := c.Int("latency-report-interval"); interval > 0 {
+			go func() {
+				for range time.Tick(time.Duration(interval) * time.Second) {
+					tracker.Dump()
+				}
+			}()
+		}
+
 		// register the data handler
 		receiver.DataHandler(func(du *udp.DataUpdate) {
+			// verify the authentication trailer if enabled (rejecting forged and replayed packets)
+			data := du.Data
+			if verifier != nil {
+				verified, err := verifier.Verify(du.Data, du.TS)
+				if err != nil {
+					fmt.Printf("Error authenticating UDP packet from '%s:%d' (error = '%v')\n", du.RemoteIP, du.RemotePort, err)
+					m.Dropped(du, auth.FailureReason(err))
+					return
+				}
+				data = verified
+			}
+
+			// decode the udp data as a ccx packet
+			packet, err := ccx.Decode(data)
+
+			// check if the packet is a duplicate burst copy, and update the metrics
+			check := dedup.Result{}
+			if err == nil {
+				check = detector.Check(packet.TagMAC(), packet.EmanateHeader.Sequence, du.TS)
+			}
+			duplicate := check.Duplicate
+			m.Observe(du, packet, err, check)
+
+			// skip the packets not matching the filter expression
+			if packetFilter != nil && !packetFilter.Match(du, packet) {
+				return
+			}
+
 			fmt.Printf("\nUDP PACKET RECEIVED\n")
 			fmt.Printf("===================\n\n")
 			fmt.Printf("%s\n", hex.Dump(du.Data))
@@ -48,14 +380,94 @@ func main() {
 			fmt.Printf("  - Total Bytes = %d\n", len(du.Data))
 			fmt.Printf("  - Remote Addr = %s:%d\n", du.RemoteIP, du.RemotePort)
 
-			// parse the udp data as a ccx packet and dump to the console
-			if err := ccx.Parse(du.Data); err != nil {
+			// if the static packet fields were decoded
+			if packet != nil {
+				// dump the decoded packet to the console
+				ccx.Dump(packet)
+
+				// record the latency if the packet includes the test-only send timestamp
+				if ts, ok := packet.SendTimestamp(); ok {
+					d := tracker.Record(packet.TagMAC(), ts, du.TS)
+					fmt.Printf("  - Send Latency = %s (test-only)\n", d)
+				}
+			}
+
+			// update the last-known state of the tag and write the packet history
+			if err == nil {
+				store.Update(du, packet)
+				if hist != nil {
+					if err := hist.Record(du, packet); err != nil {
+						fmt.Printf("Error recording packet history (error = '%v')\n", err)
+					}
+				}
+			}
+
+			// evaluate the alert rules (duplicate burst copies carry the same values)
+			if err == nil && !duplicate && alerts != nil {
+				alerts.Evaluate(du.TS, packet)
+			}
+
+			// track the access point location of the tag (from every copy of the burst if correlated)
+			if err == nil && correlator != nil {
+				correlator.Add(du.TS, packet)
+			} else if err == nil && !duplicate {
+				updateLocation(packet.TagMAC(), packet.APMAC(), du.TS)
+			}
+
+			// track the utility state of the tag
+			if err == nil && !duplicate {
+				if state, ok := packet.UtilState(); ok {
+					utilTracker.Update(packet.TagMAC(), du.TS, state)
+				}
+			}
+
+			// track the reporting interval of the tag
+			if err == nil && !duplicate && monitor != nil {
+				monitor.Seen(packet.TagMAC(), du.TS)
+			}
+
+			// publish the fully decoded packet to every output
+			if err == nil && !(duplicate && suppressDuplicates) {
+				publish(outputs, output.NewPacketMessage(du, packet))
+			}
+
+			// if an error occurred while decoding the packet
+			if err != nil {
 				fmt.Printf("Error receiving UDP data ('%v')\n", err)
 			}
 		})
 
-		// start receiving packets
+		// the processing stages flushed and closed in order once the packets are no longer received
+		stages := &pipeline{
+			correlator: correlator,
+			alerts:     alerts,
+			monitor:    monitor,
+			outputs:    outputs,
+			hist:       hist,
+		}
+
+		// replay the packets of a file (or stdin) instead if enabled (through the same source filters,
+		// rate limits, and data handler), then flush the outputs and exit
+		if input := c.String("input"); input != "" {
+			source := udp.NewFileSource(&udp.FileSourceOptions{
+				Path: input,
+				Port: c.Int("input-port"),
+				Pace: c.Bool("input-pace"),
+			})
+			handleSignals(func() { source.Close() })
+			if err := receiver.Replay(source); err != nil {
+				fmt.Printf("Error replaying input '%s' (error = '%v')\n\n", input, err)
+				stages.shutdown()
+				os.Exit(1)
+			}
+			stages.shutdown()
+			return nil
+		}
+
+		// start receiving packets (until interrupted or terminated), then flush the processing stages
+		handleSignals(func() { receiver.Close() })
 		receiver.Run()
+		stages.shutdown()
 
 		return nil
 	}
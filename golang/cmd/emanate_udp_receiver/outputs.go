@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/output"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+	"github.com/urfave/cli"
+)
+
+// outputFlags defines the cli flags of the decoded packet outputs
+var outputFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "webhook-url",
+		Value: "",
+		Usage: "http url that each decoded packet is posted to as json (disabled if empty)",
+	},
+	cli.StringSliceFlag{
+		Name:  "webhook-header",
+		Usage: "custom 'Name: Value' http header added to each webhook request (repeatable)",
+	},
+	cli.IntFlag{
+		Name:  "webhook-timeout-ms",
+		Value: 5000,
+		Usage: "webhook http request timeout",
+	},
+	cli.IntFlag{
+		Name:  "webhook-batch-size",
+		Value: 1,
+		Usage: "number of decoded packets posted per webhook request (batches are posted as a json array)",
+	},
+	cli.IntFlag{
+		Name:  "webhook-batch-interval-ms",
+		Value: 1000,
+		Usage: "maximum delay before a partial webhook batch is posted",
+	},
+	cli.IntFlag{
+		Name:  "webhook-retries",
+		Value: 3,
+		Usage: "number of webhook request retries (with exponential backoff) before queueing",
+	},
+	cli.StringFlag{
+		Name:  "webhook-queue-dir",
+		Value: "",
+		Usage: "directory of the disk-backed queue used while the webhook endpoint is down",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-broker",
+		Value: "",
+		Usage: "mqtt broker url (e.g. 'tcp://localhost:1883' or 'ssl://localhost:8883') that decoded packets are published to (disabled if empty)",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-client-id",
+		Value: output.DefaultMQTTClientID,
+		Usage: "mqtt client id",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-username",
+		Value: "",
+		Usage: "mqtt username",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-password",
+		Value: "",
+		Usage: "mqtt password",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-topic",
+		Value: output.DefaultMQTTTopicTemplate,
+		Usage: "mqtt topic template ('{tag}' is the tag mac-address, '{kind}' is the telemetry kind)",
+	},
+	cli.IntFlag{
+		Name:  "mqtt-qos",
+		Value: 0,
+		Usage: "mqtt publish quality-of-service level (0 or 1)",
+	},
+	cli.BoolFlag{
+		Name:  "mqtt-retain",
+		Usage: "publishes retained messages so subscribers receive the last state of each tag",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-ca-file",
+		Value: "",
+		Usage: "pem file of the certificate authorities trusted for mqtt tls connections",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-cert-file",
+		Value: "",
+		Usage: "pem file of the mqtt tls client certificate",
+	},
+	cli.StringFlag{
+		Name:  "mqtt-key-file",
+		Value: "",
+		Usage: "pem file of the mqtt tls client private key",
+	},
+	cli.BoolFlag{
+		Name:  "mqtt-insecure",
+		Usage: "skips verification of the mqtt broker tls certificate",
+	},
+}
+
+// createOutputs creates every decoded packet output enabled by the cli flags
+func createOutputs(c *cli.Context) ([]output.Output, error) {
+	outputs := []output.Output{}
+
+	// create the webhook output if enabled
+	if url := c.String("webhook-url"); url != "" {
+		// parse the custom http headers
+		headers := map[string]string{}
+		for _, h := range c.StringSlice("webhook-header") {
+			parts := strings.SplitN(h, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid webhook header '%s' (expected 'Name: Value')", h)
+			}
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+
+		// create the webhook output
+		webhook, err := output.NewWebhook(&output.WebhookOptions{
+			URL:           url,
+			Headers:       headers,
+			Timeout:       time.Duration(c.Int("webhook-timeout-ms")) * time.Millisecond,
+			BatchSize:     c.Int("webhook-batch-size"),
+			BatchInterval: time.Duration(c.Int("webhook-batch-interval-ms")) * time.Millisecond,
+			MaxRetries:    c.Int("webhook-retries"),
+			QueueDir:      c.String("webhook-queue-dir"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, webhook)
+	}
+
+	// create the mqtt output if enabled
+	if broker := c.String("mqtt-broker"); broker != "" {
+		// enable tls if any of the tls options are given
+		var tlsFiles *util.TLSFiles
+		if c.String("mqtt-ca-file") != "" || c.String("mqtt-cert-file") != "" || c.Bool("mqtt-insecure") {
+			tlsFiles = &util.TLSFiles{
+				CAFile:             c.String("mqtt-ca-file"),
+				CertFile:           c.String("mqtt-cert-file"),
+				KeyFile:            c.String("mqtt-key-file"),
+				InsecureSkipVerify: c.Bool("mqtt-insecure"),
+			}
+		}
+
+		// create the mqtt output
+		mqtt, err := output.NewMQTT(&output.MQTTOptions{
+			Broker:        broker,
+			ClientID:      c.String("mqtt-client-id"),
+			Username:      c.String("mqtt-username"),
+			Password:      c.String("mqtt-password"),
+			TopicTemplate: c.String("mqtt-topic"),
+			QoS:           byte(c.Int("mqtt-qos")),
+			Retain:        c.Bool("mqtt-retain"),
+			TLS:           tlsFiles,
+		})
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, mqtt)
+	}
+
+	// return the created outputs
+	return outputs, nil
+}
+
+// publish publishes the given message to every output
+func publish(outputs []output.Output, m *output.Message) {
+	for _, o := range outputs {
+		if err := o.Publish(m); err != nil {
+			fmt.Printf("Error publishing '%s' message (error = '%v')\n", m.Type, err)
+		}
+	}
+}
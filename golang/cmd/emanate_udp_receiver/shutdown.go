@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/alert"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/burst"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/heartbeat"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/history"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/output"
+)
+
+// pipeline holds the packet processing stages that are flushed and closed when the receiver stops
+type pipeline struct {
+	correlator *burst.Correlator
+	alerts     *alert.Engine
+	monitor    *heartbeat.Monitor
+	outputs    []output.Output
+	hist       *history.History
+}
+
+// shutdown flushes and closes the stages in order, each stage once the stages publishing to it are
+// closed (the pending bursts are published first, then the alert notifiers and the heartbeat monitor
+// are closed, and finally the outputs and the packet history)
+func (p *pipeline) shutdown() {
+	// publish the pending bursts
+	if p.correlator != nil {
+		p.correlator.Close()
+	}
+
+	// close the alert notifiers and stop the missing tag checks
+	if p.alerts != nil {
+		if err := p.alerts.Close(); err != nil {
+			fmt.Printf("Error closing alert notifiers (error = '%v')\n", err)
+		}
+	}
+	if p.monitor != nil {
+		p.monitor.Close()
+	}
+
+	// deliver the pending output messages and close the packet history
+	for _, o := range p.outputs {
+		if err := o.Close(); err != nil {
+			fmt.Printf("Error closing output (error = '%v')\n", err)
+		}
+	}
+	if p.hist != nil {
+		if err := p.hist.Close(); err != nil {
+			fmt.Printf("Error closing history database (error = '%v')\n", err)
+		}
+	}
+}
+
+// handleSignals calls the given stop function once an interrupt or termination signal is received
+func handleSignals(stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-signals
+		fmt.Printf("\nReceived '%v' signal, shutting down\n", s)
+		stop()
+	}()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+	"github.com/urfave/cli"
+)
+
+// transportFlags defines the cli flags of the tcp and tls transports
+var transportFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "transport",
+		Value: "udp",
+		Usage: "receives the packets over 'udp', or as length prefixed packets over 'tcp' or 'tls' connections",
+	},
+	cli.StringFlag{
+		Name:  "tls-ca-file",
+		Value: "",
+		Usage: "pem ca file that the tls sender certificates must be signed by (tls transport)",
+	},
+	cli.StringFlag{
+		Name:  "tls-cert-file",
+		Value: "",
+		Usage: "pem tls receiver certificate file (tls transport)",
+	},
+	cli.StringFlag{
+		Name:  "tls-key-file",
+		Value: "",
+		Usage: "pem tls receiver private key file (tls transport)",
+	},
+}
+
+// dtlsFlags defines the cli flags of the dtls transport
+var dtlsFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "dtls",
+		Usage: "receives the packets over dtls 1.2 sessions instead of plain udp",
+	},
+	cli.StringFlag{
+		Name:  "dtls-psk",
+		Value: "",
+		Usage: "hex dtls pre-shared key (psk mode, takes precedence over the certificate files)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-ca-file",
+		Value: "",
+		Usage: "pem ca file that the dtls sender certificates must be signed by (certificate mode)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-cert-file",
+		Value: "",
+		Usage: "pem dtls receiver certificate file (certificate mode)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-key-file",
+		Value: "",
+		Usage: "pem dtls receiver private key file (certificate mode)",
+	},
+}
+
+// createDTLSOptions creates the dtls transport options if enabled by the cli flags
+func createDTLSOptions(c *cli.Context) (*udp.DTLSOptions, error) {
+	// if the dtls transport is disabled
+	if !c.Bool("dtls") {
+		return nil, nil
+	}
+
+	// pre-shared key mode
+	if hexKey := c.String("dtls-psk"); hexKey != "" {
+		psk, err := hex.DecodeString(hexKey)
+		if err != nil || len(psk) == 0 {
+			return nil, fmt.Errorf("Invalid DTLS pre-shared key (key is not hex encoded)")
+		}
+		return &udp.DTLSOptions{PSK: psk}, nil
+	}
+
+	// certificate mode
+	return &udp.DTLSOptions{
+		TLS: &util.TLSFiles{
+			CAFile:   c.String("dtls-ca-file"),
+			CertFile: c.String("dtls-cert-file"),
+			KeyFile:  c.String("dtls-key-file"),
+		},
+	}, nil
+}
+
+// createTransportOptions returns the transport name and the tls certificate files (tls transport only)
+// of the cli flags
+func createTransportOptions(c *cli.Context) (string, *util.TLSFiles, error) {
+	// validate the transport name
+	transport, err := udp.ParseTransport(c.String("transport"))
+	if err != nil || transport != udp.TransportTLS {
+		return transport, nil, err
+	}
+
+	// get the tls certificate files
+	return transport, &util.TLSFiles{
+		CAFile:   c.String("tls-ca-file"),
+		CertFile: c.String("tls-cert-file"),
+		KeyFile:  c.String("tls-key-file"),
+	}, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/fuzz"
+	"github.com/urfave/cli"
+)
+
+// fuzzLogEntry defines the json log entry written for each transmitted malformed packet
+type fuzzLogEntry struct {
+	*fuzz.Case
+	TS  time.Time `json:"ts"`
+	Len int       `json:"len"`
+	Hex string    `json:"hex"`
+}
+
+// fuzzCommand defines the cli 'fuzz' sub-command
+var fuzzCommand = cli.Command{
+	Name:      "fuzz",
+	Usage:     "sends malformed ccx packets for parser robustness testing",
+	UsageText: "emanate_udp_sender [global options] fuzz --seed <SEED> --count <COUNT> [options]",
+	Flags: []cli.Flag{
+		cli.Int64Flag{
+			Name:  "seed",
+			Value: 0,
+			Usage: "random seed used to reproduce a fuzz run (0 uses the current time)",
+		},
+		cli.IntFlag{
+			Name:  "count",
+			Value: 100,
+			Usage: "number of malformed udp packets to send",
+		},
+		cli.IntFlag{
+			Name:  "interval-ms",
+			Value: 10,
+			Usage: "delay interval between malformed udp packets",
+		},
+		cli.StringFlag{
+			Name:  "strategies",
+			Value: strings.Join(fuzz.StrategyNames(), ","),
+			Usage: "comma-separated list of mutation strategies to apply",
+		},
+		cli.StringFlag{
+			Name:  "log",
+			Value: "",
+			Usage: "file path of the json-lines log of every sent packet",
+		},
+	},
+	Action: runFuzz,
+}
+
+func runFuzz(c *cli.Context) error {
+	// create the packet sender (udp, tcp, tls, dtls, or file)
+	sender := createSender(c)
+	defer sender.Close()
+
+	// get the random seed (so the run can be reproduced later)
+	seed := c.Int64("seed")
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	// create the packet fuzzer
+	fuzzer, err := fuzz.NewFuzzer(&fuzz.FuzzerOptions{
+		Seed:       seed,
+		Strategies: strings.Split(c.String("strategies"), ","),
+	})
+	if err != nil {
+		exitNowWithError("cannot create packet fuzzer", err)
+	}
+
+	// open the json-lines log file if given
+	var logEncoder *json.Encoder
+	if path := c.String("log"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			exitNowWithError(fmt.Sprintf("cannot create fuzz log file '%s'", path), err)
+		}
+		defer f.Close()
+		logEncoder = json.NewEncoder(f)
+	}
+
+	// build the kitchen-sink ccx packet used as the valid base of every mutation
+	data, err := buildPacket(c, true).Pack()
+	if err != nil {
+		exitNowWithError("cannot convert UDP packet into bytes", err)
+	}
+
+	log.Printf("Fuzzing with seed '%d'", seed)
+
+	// generate and transmit each malformed packet
+	count := c.Int("count")
+	for i := 0; i < count; i++ {
+		// wait for the configured interval time between packets
+		if i > 0 {
+			time.Sleep(time.Duration(c.Int("interval-ms")) * time.Millisecond)
+		}
+
+		// generate the next malformed packet
+		fc := fuzzer.Next(data)
+		log.Printf("Fuzz case #%d: %s (%s)", fc.Index, fc.Strategy, fc.Detail)
+
+		// log the malformed packet if enabled
+		if logEncoder != nil {
+			entry := &fuzzLogEntry{
+				Case: fc,
+				TS:   time.Now(),
+				Len:  len(fc.Data),
+				Hex:  hex.EncodeToString(fc.Data),
+			}
+			if err := logEncoder.Encode(entry); err != nil {
+				exitNowWithError("cannot write fuzz log entry", err)
+			}
+		}
+
+		// send the malformed udp packet
+		sender.Transmit(fc.Data)
+	}
+
+	// log that we are done
+	log.Printf("DONE! (seed = '%d')", seed)
+	fmt.Println("")
+
+	// return successfully
+	return nil
+}
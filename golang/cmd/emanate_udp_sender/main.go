@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/EmanateWireless/emanate-udp-tools/golang/auth"
 	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
 	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
 	"github.com/urfave/cli"
@@ -44,6 +44,21 @@ func main() {
 			Value: 9999,
 			Usage: "udp target port number",
 		},
+		cli.IntFlag{
+			Name:  "write-buffer",
+			Value: 0,
+			Usage: "kernel send buffer bytes of the socket (SO_SNDBUF, the kernel default if 0)",
+		},
+		cli.IntFlag{
+			Name:  "dscp",
+			Value: 0,
+			Usage: "differentiated services code point marking every packet (0-63, e.g. 46 for expedited forwarding, unmarked if 0)",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: "",
+			Usage: "writes the packets as hex lines to the given file ('-' for stdout) instead of sending them",
+		},
 		cli.BoolTFlag{
 			Name:  "all",
 			Usage: "sends all possible udp message options for testing",
@@ -101,7 +116,7 @@ func main() {
 		cli.IntFlag{
 			Name:  "battery-tolerance",
 			Value: 0,
-			Usage: "battery prediction tolerance percentage (0-100)",
+			Usage: "battery prediction tolerance percentage (0-70)",
 		},
 		cli.BoolTFlag{
 			Name:  "button-pressed",
@@ -130,15 +145,34 @@ func main() {
 			Value: 0,
 			Usage: "product-type code set in the ccx 'system group'",
 		},
+		cli.BoolFlag{
+			Name:  "send-timestamp",
+			Usage: "adds a test-only send timestamp telemetry status for latency measurement",
+		},
+		cli.StringFlag{
+			Name:  "auth-key",
+			Value: "",
+			Usage: "hex hmac-sha256 key used to append an authentication trailer to each packet (disabled if empty)",
+		},
+		cli.IntFlag{
+			Name:  "auth-key-id",
+			Value: 1,
+			Usage: "key id of the authentication key (0-255)",
+		},
+	}
+	app.Flags = append(app.Flags, transportFlags...)
+	app.Flags = append(app.Flags, dtlsFlags...)
+
+	// define the cli sub-commands
+	app.Commands = []cli.Command{
+		fuzzCommand,
 	}
 
 	// define the cli execution handler
 	app.Action = func(c *cli.Context) error {
-		// create a udp sender instance
-		sender := udp.NewSender(&udp.SenderOptions{
-			Host: c.String("host"),
-			Port: c.Int("port"),
-		})
+		// create the packet sender (udp, tcp, tls, dtls, or file)
+		sender := createSender(c)
+		defer sender.Close()
 
 		// check if the kitchen-sink 'all' option is enabled
 		sendAll := false
@@ -147,151 +181,36 @@ func main() {
 			sendAll = true
 		}
 
-		// create a ccx packet
-		packet := ccx.NewPacket()
-
-		// set the burst length to the number of duplicate udp packets + 1 original
-		packet.SetBurstLength(uint8(c.Int("num-dups") + 1))
-
-		// set the sending tag's mac-address
-		packet.SetTagMACAddress(c.String("tag-mac"))
-
-		// set the associated wifi AP's mac-address
-		packet.SetAPMACAddress(c.String("ap-mac"))
-
-		// if the sequence number is given
-		if sendAll || c.GlobalIsSet("seq") {
-			// get the sequence number flag value
-			seq := c.Int("seq")
-
-			// validate the given sequence number
-			if (seq < MinSeqNumber) || (seq > MaxSeqNumber) {
-				msg := fmt.Sprintf("sequence number must be between '%d' and '%d' (inclusive)",
-					MinSeqNumber, MaxSeqNumber)
-				exitNow(msg)
-			}
-
-			// set the packet's sequence number
-			packet.SetSequenceNumber(uint16(seq))
-		}
+		// build the ccx packet from the cli options
+		packet := buildPacket(c, sendAll)
 
-		// if the util-state option is given
-		if sendAll || c.GlobalIsSet("util-state") {
-			// get the utility-state flag value
-			util := strings.ToLower(c.String("util-state"))
-
-			// add the util-state telemetry value to the packet
-			var err error
-			switch util {
-			case "unplugged":
-				err = packet.SetUtilState(ccx.UtilStateUnplugged)
-			case "off":
-				err = packet.SetUtilState(ccx.UtilStatePluggedInOff)
-			case "idle":
-				err = packet.SetUtilState(ccx.UtilStatePluggedInIdle)
-			case "active":
-				err = packet.SetUtilState(ccx.UtilStatePluggedInActive)
-			default:
-				// display the cli usage and exit with an error
-				exitNow("'util-state' value must be either 'unplugged', 'off', idle', or 'active'")
-			}
-
-			// if an error occurred while addeding the util-state telemetry to the packet
-			if err != nil {
-				msg := fmt.Sprintf("cannot add util-state '%s' to UDP packet", util)
-				exitNowWithError(msg, err)
-			}
-		}
-
-		// set the battery values
-		packet.SetBatteryInfo(&ccx.BatteryInfo{
-			TolerancePercent: uint8(c.Int("battery-tolerance")),
-			PercentRemaining: uint8(c.Int("battery-charge")),
-			DaysRemaining:    uint16(c.Int("battery-days-remaining")),
-			AgeDays:          uint32(c.Int("battery-age")),
-		})
-
-		// if the temperature option is given
-		if sendAll || c.GlobalIsSet("temp") {
-			// add the temperature telemetry value to the packet
-			if err := packet.SetTemperature(float32(c.Float64("temp"))); err != nil {
-				exitNowWithError("cannot add temperature to UDP packet", err)
-			}
-		}
-
-		// if the door-open-percent option is given
-		if sendAll || c.GlobalIsSet("door-open-percent") {
-			// get the door-open-percent flag value
-			percent := c.Int("door-open-percent")
-
-			// validate the value
-			if (percent < 0) || (percent > 100) {
-				exitNow("door-open-percent must be between 0 and 100")
-			}
-
-			// add the door-open telemetry status to the packet
-			if err := packet.SetDoorOpenPercent(percent); err != nil {
-				exitNowWithError("cannot add 'door-open-percent' to UDP packet", err)
-			}
-		}
-
-		// if the high-power-percent option is given
-		if sendAll || c.GlobalIsSet("high-power-percent") {
-			// get the high-power-percent flag value
-			percent := c.Int("high-power-percent")
-
-			// validate the value
-			if (percent < 0) || (percent > 100) {
-				exitNow("high-power-percent must be between 0 and 100")
-			}
-
-			// add the high-power telemetry status to the packet
-			if err := packet.SetHighPowerPercent(percent); err != nil {
-				exitNowWithError("cannot add 'high-power-percent' to UDP packet", err)
-			}
-		}
-
-		// if the product-type option is given
-		if c.GlobalIsSet("product-type") {
-			// get the product-type flag value
-			pt := uint16(c.Int("product-type"))
-
-			// set the product-type value
-			packet.SetProductType(pt)
+		// encode the packet as binary bytes
+		data, err := packet.Pack()
+		if err != nil {
+			exitNowWithError("cannot convert UDP packet into bytes", err)
 		}
 
-		// if the button-pressed option is given
-		if sendAll || c.GlobalIsSet("button-pressed") {
-			// add the button-pressed telemetry status to the packet
-			if err := packet.SetButtonPressed(); err != nil {
-				exitNowWithError("cannot add 'button-pressed' to UDP packet", err)
-			}
+		// check if the test-only send timestamp option is enabled
+		options := &transmitOptions{
+			sendTimestamp: c.GlobalBool("send-timestamp"),
 		}
 
-		// if the probe-unplugged option is given
-		if sendAll || c.GlobalIsSet("probe-unplugged") {
-			// add the probe-unplugged telemetry status to the packet
-			if err := packet.SetProbeUnplugged(); err != nil {
-				exitNowWithError("cannot add 'probe-unplugged' to UDP packet", err)
+		// check if the packet authentication option is enabled
+		if hexKey := c.GlobalString("auth-key"); hexKey != "" {
+			key, err := auth.ParseKey(hexKey)
+			if err != nil {
+				exitNowWithError("invalid authentication key", err)
 			}
-		}
-
-		// if the probe-invalid-value option is given
-		if sendAll || c.GlobalIsSet("probe-invalid-value") {
-			// add the probe-invalid-value telemetry status to the packet
-			if err := packet.SetProbeInvalidValue(); err != nil {
-				exitNowWithError("cannot add 'probe-invalid-value' to UDP packet", err)
+			keyID := c.GlobalInt("auth-key-id")
+			if keyID < 0 || keyID > 255 {
+				exitNow("authentication key id must be between 0 and 255")
 			}
-		}
-
-		// encode the packet as binary bytes
-		data, err := packet.Pack()
-		if err != nil {
-			exitNowWithError("cannot convert UDP packet into bytes", err)
+			options.authKey = key
+			options.authKeyID = uint8(keyID)
 		}
 
 		// send the first udp ccx packet
-		sender.Transmit(data)
+		transmit(sender, data, options)
 
 		// if the option to send duplicate packets is given
 		if c.GlobalIsSet("num-dups") {
@@ -307,7 +226,7 @@ func main() {
 				time.Sleep(time.Duration(dupDelayMs) * time.Millisecond)
 
 				// send the next duplicate udp ccx packet
-				sender.Transmit(data)
+				transmit(sender, data, options)
 			}
 		}
 
@@ -323,6 +242,109 @@ func main() {
 	app.Run(os.Args)
 }
 
+func buildPacket(c *cli.Context, sendAll bool) *ccx.Packet {
+	// create a ccx packet builder with the burst length set to the number of duplicate udp packets +
+	// 1 original, the sending tag's mac-address, and the associated wifi AP's mac-address
+	builder := ccx.NewBuilder().
+		WithBurstLength(c.GlobalInt("num-dups") + 1).
+		WithTagMAC(c.GlobalString("tag-mac")).
+		WithAPMAC(c.GlobalString("ap-mac"))
+
+	// if the sequence number is given
+	if sendAll || c.GlobalIsSet("seq") {
+		// get the sequence number flag value
+		seq := c.GlobalInt("seq")
+
+		// validate the given sequence number
+		if (seq < MinSeqNumber) || (seq > MaxSeqNumber) {
+			msg := fmt.Sprintf("sequence number must be between '%d' and '%d' (inclusive)",
+				MinSeqNumber, MaxSeqNumber)
+			exitNow(msg)
+		}
+
+		// set the packet's sequence number
+		builder.WithSequence(uint16(seq))
+	}
+
+	// if the util-state option is given, add the util-state telemetry value
+	if sendAll || c.GlobalIsSet("util-state") {
+		builder.WithUtilState(c.GlobalString("util-state"))
+	}
+
+	// set the battery values
+	builder.WithBattery(
+		c.GlobalInt("battery-charge"),
+		c.GlobalInt("battery-tolerance"),
+		c.GlobalInt("battery-days-remaining"),
+		c.GlobalInt("battery-age"))
+
+	// if the temperature option is given, add the temperature telemetry value
+	if sendAll || c.GlobalIsSet("temp") {
+		builder.WithTemperature(c.GlobalFloat64("temp"))
+	}
+
+	// if the door-open-percent option is given, add the door-open telemetry status
+	if sendAll || c.GlobalIsSet("door-open-percent") {
+		builder.WithDoorOpenPercent(c.GlobalInt("door-open-percent"))
+	}
+
+	// if the high-power-percent option is given, add the high-power telemetry status
+	if sendAll || c.GlobalIsSet("high-power-percent") {
+		builder.WithHighPowerPercent(c.GlobalInt("high-power-percent"))
+	}
+
+	// if the product-type option is given, set the product-type value
+	if c.GlobalIsSet("product-type") {
+		builder.WithProductType(uint16(c.GlobalInt("product-type")))
+	}
+
+	// if the button-pressed option is given, add the button-pressed telemetry status
+	if sendAll || c.GlobalIsSet("button-pressed") {
+		builder.WithStatus(ccx.ButtonPressedTelemetry)
+	}
+
+	// if the probe-unplugged option is given, add the probe-unplugged telemetry status
+	if sendAll || c.GlobalIsSet("probe-unplugged") {
+		builder.WithStatus(ccx.ProbeUnpluggedTelemetry)
+	}
+
+	// if the probe-invalid-value option is given, add the probe-invalid-value telemetry status
+	if sendAll || c.GlobalIsSet("probe-invalid-value") {
+		builder.WithStatus(ccx.ProbeInvalidValueTelemetry)
+	}
+
+	// build the packet, failing on every invalid option value
+	packet, err := builder.Build()
+	if err != nil {
+		exitNowWithError("invalid packet options", err)
+	}
+
+	// return the assembled packet
+	return packet
+}
+
+// transmitOptions defines the options applied to each transmitted packet
+type transmitOptions struct {
+	sendTimestamp bool
+	authKey       []byte
+	authKeyID     uint8
+}
+
+func transmit(sender udp.PacketSender, data []byte, options *transmitOptions) {
+	// if enabled, stamp the packet with the send time immediately before transmitting
+	if options.sendTimestamp {
+		data = ccx.AppendSendTimestamp(data, time.Now())
+	}
+
+	// if enabled, append the authentication trailer (signing every preceding byte)
+	if options.authKey != nil {
+		data = auth.Sign(data, options.authKeyID, options.authKey)
+	}
+
+	// send the udp ccx packet
+	sender.Transmit(data)
+}
+
 func exitNow(msg string) {
 	exitNowWithError(msg, nil)
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+	"github.com/urfave/cli"
+)
+
+// transportFlags defines the cli flags of the tcp and tls transports
+var transportFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "transport",
+		Value: "udp",
+		Usage: "sends the packets over 'udp', or as length prefixed packets over a 'tcp' or 'tls' connection",
+	},
+	cli.StringFlag{
+		Name:  "tls-ca-file",
+		Value: "",
+		Usage: "pem ca file to verify the tls receiver certificate (the system CAs if empty, tls transport)",
+	},
+	cli.StringFlag{
+		Name:  "tls-cert-file",
+		Value: "",
+		Usage: "pem tls sender certificate file, if the receiver requires one (tls transport)",
+	},
+	cli.StringFlag{
+		Name:  "tls-key-file",
+		Value: "",
+		Usage: "pem tls sender private key file (tls transport)",
+	},
+	cli.BoolFlag{
+		Name:  "tls-insecure",
+		Usage: "skips verification of the tls receiver certificate",
+	},
+}
+
+// dtlsFlags defines the cli flags of the dtls transport
+var dtlsFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "dtls",
+		Usage: "sends the packets over a dtls 1.2 session instead of plain udp",
+	},
+	cli.StringFlag{
+		Name:  "dtls-psk",
+		Value: "",
+		Usage: "hex dtls pre-shared key (psk mode, takes precedence over the certificate files)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-psk-identity",
+		Value: "emanate",
+		Usage: "dtls pre-shared key identity (psk mode)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-ca-file",
+		Value: "",
+		Usage: "pem ca file to verify the dtls receiver certificate (certificate mode)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-cert-file",
+		Value: "",
+		Usage: "pem dtls sender certificate file, if the receiver requires one (certificate mode)",
+	},
+	cli.StringFlag{
+		Name:  "dtls-key-file",
+		Value: "",
+		Usage: "pem dtls sender private key file (certificate mode)",
+	},
+	cli.BoolFlag{
+		Name:  "dtls-insecure",
+		Usage: "skips verification of the dtls receiver certificate",
+	},
+}
+
+// createDTLSOptions creates the dtls transport options if enabled by the global cli flags
+func createDTLSOptions(c *cli.Context) (*udp.DTLSOptions, error) {
+	// if the dtls transport is disabled
+	if !c.GlobalBool("dtls") {
+		return nil, nil
+	}
+
+	// pre-shared key mode
+	if hexKey := c.GlobalString("dtls-psk"); hexKey != "" {
+		psk, err := hex.DecodeString(hexKey)
+		if err != nil || len(psk) == 0 {
+			return nil, fmt.Errorf("Invalid DTLS pre-shared key (key is not hex encoded)")
+		}
+		return &udp.DTLSOptions{
+			PSK:         psk,
+			PSKIdentity: c.GlobalString("dtls-psk-identity"),
+		}, nil
+	}
+
+	// certificate mode
+	return &udp.DTLSOptions{
+		TLS: &util.TLSFiles{
+			CAFile:             c.GlobalString("dtls-ca-file"),
+			CertFile:           c.GlobalString("dtls-cert-file"),
+			KeyFile:            c.GlobalString("dtls-key-file"),
+			InsecureSkipVerify: c.GlobalBool("dtls-insecure"),
+		},
+	}, nil
+}
+
+// createTransportOptions returns the transport name and the tls certificate files (tls transport only)
+// of the global cli flags
+func createTransportOptions(c *cli.Context) (string, *util.TLSFiles, error) {
+	// validate the transport name
+	transport, err := udp.ParseTransport(c.GlobalString("transport"))
+	if err != nil || transport != udp.TransportTLS {
+		return transport, nil, err
+	}
+
+	// get the tls certificate files
+	return transport, &util.TLSFiles{
+		CAFile:             c.GlobalString("tls-ca-file"),
+		CertFile:           c.GlobalString("tls-cert-file"),
+		KeyFile:            c.GlobalString("tls-key-file"),
+		InsecureSkipVerify: c.GlobalBool("tls-insecure"),
+	}, nil
+}
+
+// createSender creates the packet sender of the global cli flags (exiting the process on any error)
+func createSender(c *cli.Context) udp.PacketSender {
+	// write the packets to a file if enabled
+	if path := c.GlobalString("output"); path != "" {
+		sender, err := udp.NewFileSender(&udp.FileSenderOptions{Path: path})
+		if err != nil {
+			exitNowWithError(fmt.Sprintf("cannot open output file '%s'", path), err)
+		}
+		return sender
+	}
+
+	// create the tcp, tls, and dtls transport options if enabled
+	transport, tlsFiles, err := createTransportOptions(c)
+	if err != nil {
+		exitNowWithError("invalid transport options", err)
+	}
+	dtlsOptions, err := createDTLSOptions(c)
+	if err != nil {
+		exitNowWithError("invalid dtls options", err)
+	}
+
+	// create a udp sender instance
+	return udp.NewSender(&udp.SenderOptions{
+		Host:        c.GlobalString("host"),
+		Port:        c.GlobalInt("port"),
+		Transport:   transport,
+		TLS:         tlsFiles,
+		DTLS:        dtlsOptions,
+		WriteBuffer: c.GlobalInt("write-buffer"),
+		DSCP:        c.GlobalInt("dscp"),
+	})
+}
@@ -0,0 +1,106 @@
+package dedup
+
+import (
+	"sync"
+	"time"
+)
+
+// dedup default option values
+const (
+	DefaultExpiry = time.Hour
+	pruneInterval = time.Minute
+)
+
+// Detector detects the duplicate burst copies (the packets with the same sequence number as the
+// previous packet of the tag) and the skipped sequence numbers of every tag
+type Detector struct {
+	mutex     sync.Mutex
+	options   *DetectorOptions
+	tags      map[string]*tagState
+	lastPrune time.Time
+}
+
+// DetectorOptions provides the instance options
+type DetectorOptions struct {
+	// Expiry is the time after its last packet before a tag is forgotten
+	Expiry time.Duration
+}
+
+// Result defines the duplicate check result of a packet
+type Result struct {
+	// Duplicate is whether the packet is a duplicate burst copy of the previous packet
+	Duplicate bool
+
+	// Missed is the number of sequence numbers skipped since the previous packet of the tag
+	Missed int
+}
+
+// tagState tracks the last sequence number of a single tag
+type tagState struct {
+	sequence uint16
+	lastSeen time.Time
+}
+
+// NewDetector creates a new instance
+func NewDetector(options *DetectorOptions) *Detector {
+	// apply the default option values
+	if options.Expiry <= 0 {
+		options.Expiry = DefaultExpiry
+	}
+
+	// return the new instance
+	return &Detector{
+		options: options,
+		tags:    map[string]*tagState{},
+	}
+}
+
+// Check returns whether the packet of the given tag and sequence number is a duplicate burst copy,
+// and the number of skipped sequence numbers
+func (d *Detector) Check(tag string, seq uint16, ts time.Time) Result {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// forget the expired tags (at most once per prune interval)
+	if ts.Sub(d.lastPrune) >= pruneInterval {
+		d.prune(ts)
+	}
+
+	// get the tag state (or create it if this is the first packet from the tag)
+	state, ok := d.tags[tag]
+	if !ok {
+		d.tags[tag] = &tagState{sequence: seq, lastSeen: ts}
+		return Result{}
+	}
+	state.lastSeen = ts
+
+	// if the packet is a duplicate burst copy
+	if seq == state.sequence {
+		return Result{Duplicate: true}
+	}
+
+	// count any skipped sequence numbers (the sequence number wraps at 65535)
+	result := Result{}
+	if missed := seq - state.sequence - 1; missed > 0 && missed < 0x8000 {
+		result.Missed = int(missed)
+	}
+	state.sequence = seq
+	return result
+}
+
+// Len returns the number of tracked tags
+func (d *Detector) Len() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.tags)
+}
+
+func (d *Detector) prune(now time.Time) {
+	// remove the tags not seen within the expiry
+	for tag, state := range d.tags {
+		if now.Sub(state.lastSeen) > d.options.Expiry {
+			delete(d.tags, tag)
+		}
+	}
+	d.lastPrune = now
+}
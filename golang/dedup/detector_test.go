@@ -0,0 +1,52 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetector(t *testing.T) {
+	d := NewDetector(&DetectorOptions{})
+	ts := time.Unix(1700000000, 0)
+
+	// the burst copies of a packet are duplicates, and the skipped sequence numbers are counted
+	// (a backwards sequence number is a tag restart rather than a gap)
+	for i, tt := range []struct {
+		tag    string
+		seq    uint16
+		result Result
+	}{
+		{"11:22:33:44:55:66", 10, Result{}},
+		{"11:22:33:44:55:66", 10, Result{Duplicate: true}},
+		{"AA:BB:CC:DD:EE:FF", 10, Result{}},
+		{"11:22:33:44:55:66", 11, Result{}},
+		{"11:22:33:44:55:66", 14, Result{Missed: 2}},
+		{"11:22:33:44:55:66", 9, Result{}},
+		{"AA:BB:CC:DD:EE:FF", 65535, Result{}},
+	} {
+		if result := d.Check(tt.tag, tt.seq, ts); result != tt.result {
+			t.Errorf("packet #%d result = %+v, want %+v", i, result, tt.result)
+		}
+	}
+}
+
+func TestDetectorWrapAndExpiry(t *testing.T) {
+	d := NewDetector(&DetectorOptions{Expiry: 10 * time.Minute})
+	ts := time.Unix(1700000000, 0)
+
+	// the sequence number wraps at 65535
+	d.Check("11:22:33:44:55:66", 65534, ts)
+	if result := d.Check("11:22:33:44:55:66", 1, ts); result.Missed != 2 {
+		t.Errorf("wrapped result = %+v", result)
+	}
+
+	// the tags not seen within the expiry are forgotten
+	d.Check("AA:BB:CC:DD:EE:FF", 1, ts.Add(5*time.Minute))
+	d.Check("AA:BB:CC:DD:EE:FF", 2, ts.Add(12*time.Minute))
+	if n := d.Len(); n != 1 {
+		t.Errorf("tracked tags = %d, want 1", n)
+	}
+	if result := d.Check("11:22:33:44:55:66", 1, ts.Add(12*time.Minute)); result.Duplicate {
+		t.Errorf("expired tag result = %+v", result)
+	}
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// field value type constants
+const (
+	numberType = iota
+	stringType
+	macType
+	ipType
+	listType
+)
+
+// field defines a single filterable value of a received packet
+type field struct {
+	kind int
+
+	// value returns the field value of the given packet (a float64, string, or []string), or false if
+	// the packet does not include the field (the decoded packet is nil if it could not be decoded)
+	value func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool)
+}
+
+// packetNumber creates a numeric field of the decoded packet
+func packetNumber(f func(p *ccx.DecodedPacket) float64) *field {
+	return &field{kind: numberType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		if p == nil {
+			return nil, false
+		}
+		return f(p), true
+	}}
+}
+
+// fields maps each field name to its definition
+var fields = map[string]*field{
+	"tag_mac": {kind: macType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		if p == nil {
+			return nil, false
+		}
+		return p.TagMAC(), true
+	}},
+	"ap_mac": {kind: macType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		if p == nil {
+			return nil, false
+		}
+		return p.APMAC(), true
+	}},
+	"remote_ip": {kind: ipType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		return du.RemoteIP, true
+	}},
+	"remote_port": {kind: numberType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		return float64(du.RemotePort), true
+	}},
+	"length": {kind: numberType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		return float64(len(du.Data)), true
+	}},
+	"udp_version":       packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.EmanateHeader.UDPVersion) }),
+	"sequence":          packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.EmanateHeader.Sequence) }),
+	"protocol_version":  packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Header.Version) }),
+	"tx_power":          packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Header.Power) }),
+	"channel":           packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Header.Channel) }),
+	"regulatory_class":  packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Header.RegulatoryClass) }),
+	"burst_length":      packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Header.Burst) }),
+	"product_type":      packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.System.ProductType) }),
+	"battery":           packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.BatteryCharge()) }),
+	"battery_tolerance": packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.BatteryTolerance()) }),
+	"battery_days":      packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Battery.Days) }),
+	"battery_age":       packetNumber(func(p *ccx.DecodedPacket) float64 { return float64(p.Battery.Age) }),
+	"temp": {kind: numberType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		if p == nil {
+			return nil, false
+		}
+		tempC, ok := p.Temperature()
+		return float64(tempC), ok
+	}},
+	"util_state": {kind: stringType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		if p == nil {
+			return nil, false
+		}
+		return p.UtilState()
+	}},
+	"status": {kind: listType, value: func(du *udp.DataUpdate, p *ccx.DecodedPacket) (interface{}, bool) {
+		if p == nil {
+			return nil, false
+		}
+		return p.Statuses(), true
+	}},
+}
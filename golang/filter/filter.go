@@ -0,0 +1,453 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// Filter is a compiled packet filter expression, for example:
+//
+//	tag_mac == "11:22:33:44:55:66" && temp > 8
+//	util_state in ["active", "idle"]
+//	remote_ip in 10.0.0.0/8 || !(status == "BUTTON=PRESSED")
+//
+// Comparisons of the fields not included in a packet (e.g. 'temp' without temperature telemetry) are false.
+type Filter struct {
+	expr string
+	root node
+}
+
+// node is a single compiled expression node
+type node interface {
+	match(du *udp.DataUpdate, p *ccx.DecodedPacket) bool
+}
+
+// Compile parses the given filter expression
+func Compile(expr string) (*Filter, error) {
+	// split the expression into tokens
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter expression '%s' (%v)", expr, err)
+	}
+
+	// parse the tokens
+	ps := &parser{tokens: tokens}
+	root, err := ps.parseOr()
+	if err == nil && ps.pos < len(tokens) {
+		err = fmt.Errorf("unexpected '%s'", tokens[ps.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter expression '%s' (%v)", expr, err)
+	}
+
+	// return the compiled filter
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// Match returns whether the given received packet matches the filter (the decoded packet is nil if
+// the static packet fields could not be decoded)
+func (f *Filter) Match(du *udp.DataUpdate, p *ccx.DecodedPacket) bool {
+	return f.root.match(du, p)
+}
+
+// String returns the filter expression
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Fields returns the names of the filterable fields
+func Fields() []string {
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//
+// lexer
+//
+
+// token types
+const (
+	wordToken = iota
+	stringToken
+	opToken
+)
+
+// token is a single expression token
+type token struct {
+	kind int
+	text string
+}
+
+// operators lists the operator tokens (longest first)
+var operators = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"':
+			// find the end of the quoted string (skipping the escaped characters)
+			end := i + 1
+			for end < len(expr) && expr[end] != '"' {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			s, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: stringToken, text: s})
+			i = end + 1
+
+		case isWordChar(c):
+			// words are field names, keywords, numbers, mac-addresses, ip-addresses, and cidr networks
+			end := i
+			for end < len(expr) && isWordChar(expr[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: wordToken, text: expr[i:end]})
+			i = end
+
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' at offset %d", c, i)
+			}
+			tokens = append(tokens, token{kind: opToken, text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isWordChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '_' || c == '.' || c == ':' || c == '/' || c == '-' || c == '+'
+}
+
+//
+// parser
+//
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (ps *parser) peek(op string) bool {
+	return ps.pos < len(ps.tokens) && ps.tokens[ps.pos].kind == opToken && ps.tokens[ps.pos].text == op
+}
+
+func (ps *parser) next() (token, error) {
+	if ps.pos >= len(ps.tokens) {
+		return token{}, fmt.Errorf("unexpected end of expression")
+	}
+	t := ps.tokens[ps.pos]
+	ps.pos++
+	return t, nil
+}
+
+func (ps *parser) expect(op string) error {
+	t, err := ps.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != opToken || t.text != op {
+		return fmt.Errorf("expected '%s' instead of '%s'", op, t.text)
+	}
+	return nil
+}
+
+// parseOr parses 'and ( "||" and )*'
+func (ps *parser) parseOr() (node, error) {
+	left, err := ps.parseAnd()
+	for err == nil && ps.peek("||") {
+		ps.pos++
+		var right node
+		if right, err = ps.parseAnd(); err == nil {
+			left = &orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+// parseAnd parses 'unary ( "&&" unary )*'
+func (ps *parser) parseAnd() (node, error) {
+	left, err := ps.parseUnary()
+	for err == nil && ps.peek("&&") {
+		ps.pos++
+		var right node
+		if right, err = ps.parseUnary(); err == nil {
+			left = &andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+// parseUnary parses '"!" unary | "(" or ")" | comparison'
+func (ps *parser) parseUnary() (node, error) {
+	if ps.peek("!") {
+		ps.pos++
+		n, err := ps.parseUnary()
+		return &notNode{n}, err
+	}
+	if ps.peek("(") {
+		ps.pos++
+		n, err := ps.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, ps.expect(")")
+	}
+	return ps.parseComparison()
+}
+
+// parseComparison parses 'field op value | field "in" ( value | "[" value ( "," value )* "]" )'
+func (ps *parser) parseComparison() (node, error) {
+	// get the field
+	t, err := ps.next()
+	if err != nil {
+		return nil, err
+	}
+	f, ok := fields[t.text]
+	if t.kind != wordToken || !ok {
+		return nil, fmt.Errorf("unknown field '%s' (expected one of %s)", t.text, strings.Join(Fields(), ", "))
+	}
+	c := &cmpNode{name: t.text, field: f}
+
+	// get the operator
+	op, err := ps.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case op.kind == wordToken && op.text == "in":
+		c.op = "in"
+	case op.kind == opToken && (op.text == "==" || op.text == "!=" || op.text == "=~" ||
+		op.text == "<" || op.text == "<=" || op.text == ">" || op.text == ">="):
+		c.op = op.text
+	default:
+		return nil, fmt.Errorf("expected a comparison operator after '%s' instead of '%s'", c.name, op.text)
+	}
+
+	// get the value (or the list of values)
+	values := []token{}
+	if c.op == "in" && ps.peek("[") {
+		ps.pos++
+		for {
+			v, err := ps.next()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if ps.peek(",") {
+				ps.pos++
+				continue
+			}
+			if err := ps.expect("]"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	} else {
+		v, err := ps.next()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	// compile the comparison values
+	if err := c.compile(values); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//
+// expression nodes
+//
+
+type orNode struct{ left, right node }
+
+func (n *orNode) match(du *udp.DataUpdate, p *ccx.DecodedPacket) bool {
+	return n.left.match(du, p) || n.right.match(du, p)
+}
+
+type andNode struct{ left, right node }
+
+func (n *andNode) match(du *udp.DataUpdate, p *ccx.DecodedPacket) bool {
+	return n.left.match(du, p) && n.right.match(du, p)
+}
+
+type notNode struct{ n node }
+
+func (n *notNode) match(du *udp.DataUpdate, p *ccx.DecodedPacket) bool {
+	return !n.n.match(du, p)
+}
+
+// cmpNode compares a single field to one or more values
+type cmpNode struct {
+	name     string
+	field    *field
+	op       string
+	strings  []string
+	numbers  []float64
+	networks []*net.IPNet
+	re       *regexp.Regexp
+}
+
+func (c *cmpNode) compile(values []token) error {
+	// regular expressions apply to the field string values
+	if c.op == "=~" {
+		if c.field.kind == numberType {
+			return fmt.Errorf("'=~' cannot be used with the numeric field '%s'", c.name)
+		}
+		re, err := regexp.Compile(values[0].text)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression '%s' (%v)", values[0].text, err)
+		}
+		c.re = re
+		return nil
+	}
+
+	// ordering only applies to the numeric fields
+	ordering := c.op != "==" && c.op != "!=" && c.op != "in"
+	if ordering && c.field.kind != numberType {
+		return fmt.Errorf("'%s' cannot be used with the non-numeric field '%s'", c.op, c.name)
+	}
+
+	// parse the values
+	for _, v := range values {
+		if v.kind == opToken {
+			return fmt.Errorf("expected a value instead of '%s'", v.text)
+		}
+		switch c.field.kind {
+		case numberType:
+			n, err := strconv.ParseFloat(v.text, 64)
+			if err != nil {
+				return fmt.Errorf("'%s' is not a number (field '%s')", v.text, c.name)
+			}
+			c.numbers = append(c.numbers, n)
+
+		case macType:
+			c.strings = append(c.strings, util.NormalizeMAC(v.text))
+
+		case ipType:
+			// the ip-address values are single address networks
+			network, err := util.ParseNetwork(v.text)
+			if err != nil {
+				return fmt.Errorf("'%s' is not an ip-address or cidr network (field '%s')", v.text, c.name)
+			}
+			c.networks = append(c.networks, network)
+
+		default:
+			c.strings = append(c.strings, v.text)
+		}
+	}
+	return nil
+}
+
+func (c *cmpNode) match(du *udp.DataUpdate, p *ccx.DecodedPacket) bool {
+	// the comparisons of missing fields are false
+	v, ok := c.field.value(du, p)
+	if !ok {
+		return false
+	}
+
+	switch v := v.(type) {
+	case float64:
+		return c.matchNumber(v)
+
+	case []string:
+		// the list fields (e.g. 'status') match if any value matches ('!=' if no value is equal)
+		op := c.op
+		if op == "!=" {
+			op = "=="
+		}
+		any := false
+		for _, s := range v {
+			if c.matchString(op, s) {
+				any = true
+				break
+			}
+		}
+		return any != (c.op == "!=")
+
+	default:
+		return c.matchString(c.op, v.(string))
+	}
+}
+
+func (c *cmpNode) matchNumber(v float64) bool {
+	switch c.op {
+	case "==", "in":
+		for _, n := range c.numbers {
+			if v == n {
+				return true
+			}
+		}
+		return false
+	case "!=":
+		return v != c.numbers[0]
+	case "<":
+		return v < c.numbers[0]
+	case "<=":
+		return v <= c.numbers[0]
+	case ">":
+		return v > c.numbers[0]
+	default:
+		return v >= c.numbers[0]
+	}
+}
+
+func (c *cmpNode) matchString(op string, v string) bool {
+	if op == "=~" {
+		return c.re.MatchString(v)
+	}
+
+	// find whether the value equals (or is within) any of the values
+	found := false
+	if c.field.kind == ipType {
+		ip := net.ParseIP(v)
+		for _, network := range c.networks {
+			if ip != nil && network.Contains(ip) {
+				found = true
+			}
+		}
+	} else {
+		for _, s := range c.strings {
+			if v == s {
+				found = true
+			}
+		}
+	}
+	return found != (op == "!=")
+}
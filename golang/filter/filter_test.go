@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+func TestMatch(t *testing.T) {
+	// create a packet with temperature, util state, and button telemetry
+	p := ccx.NewPacket()
+	p.SetTemperature(9.5)
+	p.SetUtilState(ccx.UtilStatePluggedInActive)
+	p.SetButtonPressed()
+	data, _ := p.Pack()
+	packet, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	du := &udp.DataUpdate{RemoteIP: "10.1.2.3", RemotePort: 5000, Data: data}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`tag_mac == "11:22:33:44:55:66" && temp > 8`, true},
+		{`tag_mac == 112233445566`, true},
+		{`tag_mac != "11:22:33:44:55:66" || temp < 8`, false},
+		{`util_state in ["active", "idle"]`, true},
+		{`util_state in ["off"]`, false},
+		{`remote_ip in 10.0.0.0/8`, true},
+		{`remote_ip in [192.168.0.0/16, 10.1.2.3]`, true},
+		{`remote_ip == 10.1.2.4`, false},
+		{`status == "BUTTON=PRESSED"`, true},
+		{`status != "BUTTON=PRESSED"`, false},
+		{`status =~ "^UTIL_STATE=.*ACTIVE$"`, true},
+		{`!(battery >= 80) || sequence in [1, 2]`, true},
+		{`product_type == 0 && remote_port > 1024 && length >= 34`, true},
+		{`temp > -5.5 && temp <= 9.5`, true},
+	}
+	for _, test := range tests {
+		f, err := Compile(test.expr)
+		if err != nil {
+			t.Errorf("Compile(%s) error = %v", test.expr, err)
+			continue
+		}
+		if got := f.Match(du, packet); got != test.want {
+			t.Errorf("Match(%s) = %v, want %v", test.expr, got, test.want)
+		}
+	}
+
+	// the comparisons of missing fields are false
+	noTemp, _ := ccx.Decode(data[:ccx.TelemetryDataOffset])
+	f, _ := Compile(`temp < 100`)
+	if f.Match(du, noTemp) || f.Match(du, nil) {
+		t.Errorf("Match(%s) of a packet without temperature = true", f)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	exprs := []string{
+		``,
+		`foo == 1`,
+		`temp == "warm"`,
+		`util_state > "active"`,
+		`temp =~ "9"`,
+		`temp > 8 &&`,
+		`(temp > 8`,
+		`remote_ip in 10.0.0.0/33`,
+		`tag_mac == "11:22`,
+		`util_state in ["active" "idle"]`,
+		`temp > 8 temp < 9`,
+	}
+	for _, expr := range exprs {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%s) error = nil", expr)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package fuzz
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+// Fuzzer generates malformed CCX packets by mutating a valid packed packet
+type Fuzzer struct {
+	options    *FuzzerOptions
+	rand       *rand.Rand
+	strategies []Strategy
+	index      int
+}
+
+// FuzzerOptions provides the instance options
+type FuzzerOptions struct {
+	Seed       int64
+	Strategies []string
+}
+
+// Case defines a single generated malformed packet
+type Case struct {
+	Index    int    `json:"index"`
+	Seed     int64  `json:"seed"`
+	Strategy string `json:"strategy"`
+	Detail   string `json:"detail"`
+	Data     []byte `json:"-"`
+}
+
+// NewFuzzer creates a new instance
+func NewFuzzer(options *FuzzerOptions) (*Fuzzer, error) {
+	// if no strategies are given, use all of them
+	strategies := []Strategy{}
+	if len(options.Strategies) == 0 {
+		strategies = append(strategies, Strategies...)
+	}
+
+	// find each of the given strategies by name
+	for _, name := range options.Strategies {
+		s, ok := LookupStrategy(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("Unknown fuzz mutation strategy '%s'", name)
+		}
+		strategies = append(strategies, s)
+	}
+
+	// return the new instance
+	return &Fuzzer{
+		options:    options,
+		rand:       rand.New(rand.NewSource(options.Seed)),
+		strategies: strategies,
+	}, nil
+}
+
+// Next generates the next malformed packet from the given valid packed packet bytes
+func (f *Fuzzer) Next(data []byte) *Case {
+	// pick a random mutation strategy
+	s := f.strategies[f.rand.Intn(len(f.strategies))]
+
+	// apply the mutation to a copy of the packet bytes
+	mutated, detail := s.Mutate(f.rand, append([]byte{}, data...))
+
+	// return the generated case
+	f.index++
+	return &Case{
+		Index:    f.index,
+		Seed:     f.options.Seed,
+		Strategy: s.Name,
+		Detail:   detail,
+		Data:     mutated,
+	}
+}
+
+// telemetryOffsets returns the offset of each well-formed telemetry group in the packed packet
+func telemetryOffsets(data []byte) []int {
+	offsets := []int{}
+	cursor := ccx.TelemetryDataOffset
+
+	// walk each telemetry group using its group length (id + length bytes are not included)
+	for cursor+1 < len(data) {
+		offsets = append(offsets, cursor)
+		cursor = cursor + 2 + int(data[cursor+1])
+	}
+
+	// return the group offsets
+	return offsets
+}
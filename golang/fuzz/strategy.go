@@ -0,0 +1,177 @@
+package fuzz
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+// MutateFunc mutates the given packed packet bytes and returns the result and a description
+type MutateFunc func(r *rand.Rand, data []byte) ([]byte, string)
+
+// Strategy defines a named packet mutation strategy
+type Strategy struct {
+	Name        string
+	Description string
+	Mutate      MutateFunc
+}
+
+// Strategies lists every supported mutation strategy
+var Strategies = []Strategy{
+	{
+		Name:        "truncate",
+		Description: "truncates the packet at a random offset inside a telemetry group",
+		Mutate:      truncateGroup,
+	},
+	{
+		Name:        "wrong-length",
+		Description: "sets a random telemetry group 'Length' byte to a wrong value",
+		Mutate:      wrongLength,
+	},
+	{
+		Name:        "unknown-group",
+		Description: "appends a group with an unknown group id",
+		Mutate:      unknownGroup,
+	},
+	{
+		Name:        "odd-utf16",
+		Description: "appends a status telemetry string with an odd utf-16 byte length",
+		Mutate:      oddUTF16,
+	},
+	{
+		Name:        "oversized-status",
+		Description: "appends a status telemetry string longer than the 'Length' byte can hold",
+		Mutate:      oversizedStatus,
+	},
+	{
+		Name:        "bit-flip",
+		Description: "flips random bits anywhere in the packet",
+		Mutate:      bitFlip,
+	},
+}
+
+// StrategyNames returns the names of every supported mutation strategy
+func StrategyNames() []string {
+	names := []string{}
+	for _, s := range Strategies {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// LookupStrategy finds the mutation strategy with the given name
+func LookupStrategy(name string) (Strategy, bool) {
+	for _, s := range Strategies {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Strategy{}, false
+}
+
+func truncateGroup(r *rand.Rand, data []byte) ([]byte, string) {
+	// if the packet has no telemetry groups, truncate the static packet fields instead
+	offsets := telemetryOffsets(data)
+	if len(offsets) == 0 {
+		n := r.Intn(len(data))
+		return data[:n], fmt.Sprintf("truncated static fields to %d bytes", n)
+	}
+
+	// truncate somewhere inside a random telemetry group
+	i := r.Intn(len(offsets))
+	end := len(data)
+	if i+1 < len(offsets) {
+		end = offsets[i+1]
+	}
+	n := offsets[i] + 1 + r.Intn(end-offsets[i]-1)
+
+	// return the truncated packet
+	return data[:n], fmt.Sprintf("truncated telemetry group #%d (offset %d) to %d bytes", i, offsets[i], n)
+}
+
+func wrongLength(r *rand.Rand, data []byte) ([]byte, string) {
+	// if the packet has no telemetry groups, corrupt the battery group length instead
+	offsets := telemetryOffsets(data)
+	if len(offsets) == 0 {
+		old := data[ccx.TelemetryDataOffset-8]
+		v := uint8(r.Intn(256))
+		if v == old {
+			v = old + 1
+		}
+		data[ccx.TelemetryDataOffset-8] = v
+		return data, fmt.Sprintf("battery group length %d -> %d", old, v)
+	}
+
+	// overwrite the length byte of a random telemetry group
+	i := r.Intn(len(offsets))
+	old := data[offsets[i]+1]
+	v := uint8(r.Intn(256))
+	if v == old {
+		v = old + 1
+	}
+	data[offsets[i]+1] = v
+
+	// return the mutated packet
+	return data, fmt.Sprintf("telemetry group #%d length %d -> %d", i, old, v)
+}
+
+func unknownGroup(r *rand.Rand, data []byte) ([]byte, string) {
+	// pick a random group id that is not the telemetry group id
+	id := uint8(r.Intn(256))
+	if id == ccx.TelemetryGroupID {
+		id++
+	}
+
+	// append the unknown group with some random payload bytes
+	n := r.Intn(8)
+	data = append(data, id, uint8(n))
+	for i := 0; i < n; i++ {
+		data = append(data, uint8(r.Intn(256)))
+	}
+
+	// return the mutated packet
+	return data, fmt.Sprintf("appended unknown group id %d with %d payload bytes", id, n)
+}
+
+func oddUTF16(r *rand.Rand, data []byte) ([]byte, string) {
+	// encode a valid status string and drop the final byte
+	t := ccx.NewStatusTelemetry(ccx.ButtonPressedTelemetry)
+	status := t.Status[:len(t.Status)-1]
+	n := uint8(len(status))
+
+	// append the odd-length status telemetry
+	data = append(data, t.ID, n+2, t.Type, n)
+	data = append(data, status...)
+
+	// return the mutated packet
+	return data, fmt.Sprintf("appended %d-byte utf-16 status string", n)
+}
+
+func oversizedStatus(r *rand.Rand, data []byte) ([]byte, string) {
+	// build a status string whose encoded length overflows the 8-bit length fields
+	chars := 128 + r.Intn(128)
+	t := ccx.NewStatusTelemetry(strings.Repeat("X", chars))
+
+	// append the oversized status telemetry
+	data = append(data, t.ID, t.Length, t.Type, t.StatusLength)
+	data = append(data, t.Status...)
+
+	// return the mutated packet
+	return data, fmt.Sprintf("appended %d-byte utf-16 status string (length byte %d)", len(t.Status), t.StatusLength)
+}
+
+func bitFlip(r *rand.Rand, data []byte) ([]byte, string) {
+	// flip between 1 and 8 random bits
+	n := 1 + r.Intn(8)
+	flipped := []string{}
+	for i := 0; i < n; i++ {
+		bit := r.Intn(len(data) * 8)
+		data[bit/8] ^= 1 << uint(bit%8)
+		flipped = append(flipped, fmt.Sprintf("%d.%d", bit/8, bit%8))
+	}
+
+	// return the mutated packet
+	return data, fmt.Sprintf("flipped bits at byte.bit %s", strings.Join(flipped, ","))
+}
@@ -0,0 +1,246 @@
+package fuzz
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+)
+
+// seeds is the number of seeded mutations checked for each strategy
+const seeds = 200
+
+// basePacket packs a valid packet (with a temperature and two status telemetry groups if enabled)
+func basePacket(t *testing.T, telemetry bool) []byte {
+	p := ccx.NewPacket()
+	if telemetry {
+		if err := p.SetTemperature(21.5); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetButtonPressed(); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.SetUtilState(ccx.UtilStatePluggedInActive); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// mutate applies the given strategy with the given seed to a copy of the given packet
+func mutate(f MutateFunc, seed int64, data []byte) ([]byte, string) {
+	return f(rand.New(rand.NewSource(seed)), append([]byte{}, data...))
+}
+
+// decode decodes the given mutated packet, failing the test if the decoder panics
+func decode(t *testing.T, data []byte, detail string) (packet *ccx.DecodedPacket, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Decode() panicked on '%s' (panic = '%v', data = %x)", detail, r, data)
+		}
+	}()
+	return ccx.Decode(data)
+}
+
+func TestTruncateGroup(t *testing.T) {
+	base := basePacket(t, true)
+	offsets := telemetryOffsets(base)
+	if len(offsets) != 3 {
+		t.Fatalf("telemetry offsets = %v", offsets)
+	}
+
+	// the packet is cut inside one of its telemetry groups
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(truncateGroup, seed, base)
+		if len(mutated) <= offsets[0] || len(mutated) >= len(base) || !bytes.Equal(mutated, base[:len(mutated)]) {
+			t.Fatalf("seed %d: '%s' mutated = %x", seed, detail, mutated)
+		}
+		for _, offset := range offsets {
+			if len(mutated) == offset {
+				t.Fatalf("seed %d: '%s' truncated at a group boundary", seed, detail)
+			}
+		}
+		if _, err := decode(t, mutated, detail); !errors.Is(err, ccx.ErrTruncatedGroup) {
+			t.Fatalf("seed %d: '%s' error = %v", seed, detail, err)
+		}
+	}
+
+	// without telemetry groups the static fields are truncated instead
+	base = basePacket(t, false)
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(truncateGroup, seed, base)
+		if len(mutated) >= ccx.TelemetryDataOffset || !strings.HasPrefix(detail, "truncated static fields") {
+			t.Fatalf("seed %d: '%s' mutated = %x", seed, detail, mutated)
+		}
+		if packet, err := decode(t, mutated, detail); packet != nil || !errors.Is(err, ccx.ErrTruncatedPacket) {
+			t.Fatalf("seed %d: '%s' error = %v", seed, detail, err)
+		}
+	}
+}
+
+func TestWrongLength(t *testing.T) {
+	base := basePacket(t, true)
+	offsets := telemetryOffsets(base)
+
+	// a single telemetry group length byte is changed
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(wrongLength, seed, base)
+		changed := diff(base, mutated)
+		if len(changed) != 1 || !isLengthByte(changed[0], offsets) {
+			t.Fatalf("seed %d: '%s' changed bytes %v", seed, detail, changed)
+		}
+
+		// a length past the end of the packet is always rejected (a shorter length may realign the
+		// following bytes into valid groups)
+		_, err := decode(t, mutated, detail)
+		if int(mutated[changed[0]]) > len(mutated)-changed[0]-1 && !errors.Is(err, ccx.ErrTruncatedGroup) {
+			t.Fatalf("seed %d: '%s' error = %v", seed, detail, err)
+		}
+	}
+
+	// without telemetry groups the battery group length byte is changed instead (the static group
+	// lengths are not checked by the decoder)
+	base = basePacket(t, false)
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(wrongLength, seed, base)
+		if changed := diff(base, mutated); len(changed) != 1 || changed[0] != ccx.TelemetryDataOffset-8 {
+			t.Fatalf("seed %d: '%s' changed bytes %v", seed, detail, changed)
+		}
+		decode(t, mutated, detail)
+	}
+}
+
+func TestUnknownGroup(t *testing.T) {
+	base := basePacket(t, true)
+
+	// a group with a non-telemetry id and a matching length is appended (and skipped by the decoder)
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(unknownGroup, seed, base)
+		group := mutated[len(base):]
+		if !bytes.Equal(mutated[:len(base)], base) || len(group) < 2 || group[0] == ccx.TelemetryGroupID ||
+			int(group[1]) != len(group)-2 {
+			t.Fatalf("seed %d: '%s' appended %x", seed, detail, group)
+		}
+		packet, err := decode(t, mutated, detail)
+		if err != nil || packet.Telemetry[len(packet.Telemetry)-1].GroupID != group[0] {
+			t.Fatalf("seed %d: '%s' error = %v", seed, detail, err)
+		}
+	}
+}
+
+func TestOddUTF16(t *testing.T) {
+	base := basePacket(t, true)
+
+	// a status string with an odd byte length (but consistent length bytes) is appended
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(oddUTF16, seed, base)
+		group := mutated[len(base):]
+		if !bytes.Equal(mutated[:len(base)], base) || len(group) < 4 || group[0] != ccx.TelemetryGroupID ||
+			group[2] != ccx.StatusTelemetryType || int(group[3]) != len(group)-4 || group[3]%2 != 1 ||
+			int(group[1]) != len(group)-2 {
+			t.Fatalf("seed %d: '%s' appended %x", seed, detail, group)
+		}
+		decode(t, mutated, detail)
+	}
+}
+
+func TestOversizedStatus(t *testing.T) {
+	base := basePacket(t, true)
+
+	// a status string longer than its 8-bit length bytes can hold is appended
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(oversizedStatus, seed, base)
+		group := mutated[len(base):]
+		if !bytes.Equal(mutated[:len(base)], base) || len(group)-4 <= 255 || int(group[3]) == len(group)-4 ||
+			int(group[1]) == len(group)-2 {
+			t.Fatalf("seed %d: '%s' appended %d bytes", seed, detail, len(group))
+		}
+		decode(t, mutated, detail)
+	}
+}
+
+func TestBitFlip(t *testing.T) {
+	base := basePacket(t, true)
+
+	// between 1 and 8 bits are flipped (a bit flipped twice is restored)
+	for seed := int64(0); seed < seeds; seed++ {
+		mutated, detail := mutate(bitFlip, seed, base)
+		flips := strings.Count(strings.TrimPrefix(detail, "flipped bits at byte.bit "), ".")
+		bits := 0
+		for i := range base {
+			for x := base[i] ^ mutated[i]; x != 0; x &= x - 1 {
+				bits++
+			}
+		}
+		if len(mutated) != len(base) || flips < 1 || flips > 8 || bits > flips || bits%2 != flips%2 {
+			t.Fatalf("seed %d: '%s' flipped %d bits", seed, detail, bits)
+		}
+		decode(t, mutated, detail)
+	}
+}
+
+func TestFuzzer(t *testing.T) {
+	base := basePacket(t, true)
+
+	// the same seed generates the same cases
+	a, err := NewFuzzer(&FuzzerOptions{Seed: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewFuzzer(&FuzzerOptions{Seed: 42})
+	for i := 1; i <= seeds; i++ {
+		ca, cb := a.Next(base), b.Next(base)
+		if ca.Index != i || ca.Seed != 42 || ca.Strategy != cb.Strategy || ca.Detail != cb.Detail ||
+			!bytes.Equal(ca.Data, cb.Data) {
+			t.Fatalf("case %+v != %+v", ca, cb)
+		}
+		decode(t, ca.Data, ca.Detail)
+	}
+
+	// the given strategies are used only, and the base packet is not modified
+	f, err := NewFuzzer(&FuzzerOptions{Seed: 1, Strategies: []string{" bit-flip", "truncate"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < seeds; i++ {
+		if c := f.Next(base); c.Strategy != "bit-flip" && c.Strategy != "truncate" {
+			t.Fatalf("case strategy = %s", c.Strategy)
+		}
+	}
+	if !bytes.Equal(base, basePacket(t, true)) {
+		t.Error("base packet modified")
+	}
+
+	// the unknown strategies are rejected
+	if _, err := NewFuzzer(&FuzzerOptions{Strategies: []string{"unknown"}}); err == nil {
+		t.Error("NewFuzzer() accepted an unknown strategy")
+	}
+}
+
+// diff returns the offsets of the bytes that differ between the given same-length packets
+func diff(a []byte, b []byte) []int {
+	offsets := []int{}
+	for i := range a {
+		if a[i] != b[i] {
+			offsets = append(offsets, i)
+		}
+	}
+	return offsets
+}
+
+// isLengthByte returns whether the given offset is the length byte of one of the given groups
+func isLengthByte(offset int, groups []int) bool {
+	for _, g := range groups {
+		if offset == g+1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// heartbeat default constants
+const (
+	DefaultMisses        = 3
+	DefaultCheckInterval = 10 * time.Second
+	learnWeight          = 0.2
+	minLearnedInterval   = time.Second
+)
+
+// event type constants
+const (
+	TagSilentEventType    = "tag_silent"
+	TagRecoveredEventType = "tag_recovered"
+)
+
+// Monitor tracks the reporting interval of every tag and detects the tags that stop reporting
+type Monitor struct {
+	mutex   sync.Mutex
+	options *MonitorOptions
+	tags    map[string]*tagState
+	done    chan struct{}
+}
+
+// MonitorOptions provides the instance options
+type MonitorOptions struct {
+	// Interval is the expected reporting interval of every tag (learned from the traffic if zero)
+	Interval time.Duration
+
+	// Intervals overrides the expected reporting interval of individual tags (these tags are tracked
+	// from the start, so a tag that never reports is also silent)
+	Intervals map[string]time.Duration
+
+	// Start is the time the tags with configured intervals are expected to report from (now if zero)
+	Start time.Time
+
+	// Misses is the number of missed intervals before a tag is silent
+	Misses int
+
+	// CheckInterval is the interval between the background silent tag checks
+	CheckInterval time.Duration
+
+	// Handler is called with every silent and recovered tag event
+	Handler func(e *Event)
+}
+
+// Event defines a silent or recovered tag (the last seen time of a configured tag that never reported
+// is the monitor start time)
+type Event struct {
+	Type            string    `json:"type"`
+	TagMAC          string    `json:"tag_mac"`
+	TS              time.Time `json:"ts"`
+	LastSeen        time.Time `json:"last_seen"`
+	IntervalSeconds float64   `json:"interval_seconds"`
+	SilentSeconds   float64   `json:"silent_seconds"`
+	Misses          int       `json:"misses"`
+}
+
+// tagState tracks the reporting interval of a single tag
+type tagState struct {
+	lastSeen time.Time
+	learned  time.Duration
+	silent   bool
+	seen     bool
+}
+
+// NewMonitor creates a new instance and starts the background silent tag checks
+func NewMonitor(options *MonitorOptions) *Monitor {
+	// apply the default option values (to a copy, so the caller's options are not modified)
+	copied := *options
+	options = &copied
+	if options.Misses <= 0 {
+		options.Misses = DefaultMisses
+	}
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = DefaultCheckInterval
+	}
+	if options.Start.IsZero() {
+		options.Start = time.Now()
+	}
+
+	// normalize the tag mac-addresses of the configured intervals
+	intervals := map[string]time.Duration{}
+	for tag, d := range options.Intervals {
+		intervals[util.NormalizeMAC(tag)] = d
+	}
+	options.Intervals = intervals
+
+	// create the new instance
+	m := &Monitor{
+		options: options,
+		tags:    map[string]*tagState{},
+		done:    make(chan struct{}),
+	}
+
+	// track the configured tags from the start (so they are silent if they never report)
+	for tag := range intervals {
+		m.tags[tag] = &tagState{lastSeen: options.Start}
+	}
+
+	// start the background checks
+	go m.run()
+
+	// return the new instance
+	return m
+}
+
+// Seen records a packet of the given tag received at the given time and returns the recovered
+// event if the tag was silent (or nil)
+func (m *Monitor) Seen(tagMAC string, ts time.Time) *Event {
+	m.mutex.Lock()
+
+	// get the tag state (or create it if this is the first packet)
+	s, ok := m.tags[tagMAC]
+	if !ok {
+		m.tags[tagMAC] = &tagState{lastSeen: ts, seen: true}
+		m.mutex.Unlock()
+		return nil
+	}
+
+	// the first packet of a configured tag starts its tracking (in any order to the start time, e.g.
+	// when replaying captured traffic), and recovers it if it was silent
+	if !s.seen {
+		var event *Event
+		if s.silent {
+			s.silent = false
+			event = m.newEvent(TagRecoveredEventType, tagMAC, s, ts)
+		}
+		s.lastSeen = ts
+		s.seen = true
+		m.mutex.Unlock()
+		m.notify(event)
+		return event
+	}
+
+	// ignore the packets received out of order
+	gap := ts.Sub(s.lastSeen)
+	if gap < 0 {
+		m.mutex.Unlock()
+		return nil
+	}
+
+	// if the tag was silent, the gap is an outage and is not learned
+	var event *Event
+	if s.silent {
+		s.silent = false
+		event = m.newEvent(TagRecoveredEventType, tagMAC, s, ts)
+	} else if gap >= minLearnedInterval {
+		// learn the reporting interval as the moving average of the gaps between packets
+		if s.learned == 0 {
+			s.learned = gap
+		} else {
+			s.learned = time.Duration(learnWeight*float64(gap) + (1-learnWeight)*float64(s.learned))
+		}
+	}
+	s.lastSeen = ts
+	m.mutex.Unlock()
+
+	// notify the recovered event
+	m.notify(event)
+
+	// return the recovered event
+	return event
+}
+
+// Check finds the tags that missed the allowed number of intervals at the given time and returns
+// the new silent events
+func (m *Monitor) Check(now time.Time) []*Event {
+	m.mutex.Lock()
+	events := []*Event{}
+	for tag, s := range m.tags {
+		// skip the silent tags and the tags without a known interval
+		interval := m.interval(tag, s)
+		if s.silent || interval <= 0 {
+			continue
+		}
+
+		// the tag is silent once it misses the allowed number of intervals
+		if now.Sub(s.lastSeen) > time.Duration(m.options.Misses)*interval {
+			s.silent = true
+			events = append(events, m.newEvent(TagSilentEventType, tag, s, now))
+		}
+	}
+	m.mutex.Unlock()
+
+	// sort the events by tag mac-address
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].TagMAC < events[j].TagMAC
+	})
+
+	// notify the silent events
+	if m.options.Handler != nil {
+		for _, e := range events {
+			m.options.Handler(e)
+		}
+	}
+
+	// return the silent events
+	return events
+}
+
+// Close stops the background checks
+func (m *Monitor) Close() error {
+	close(m.done)
+	return nil
+}
+
+func (m *Monitor) run() {
+	ticker := time.NewTicker(m.options.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			m.Check(now)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// notify calls the handler with the given event (if any)
+func (m *Monitor) notify(e *Event) {
+	if e != nil && m.options.Handler != nil {
+		m.options.Handler(e)
+	}
+}
+
+// interval returns the expected reporting interval of the given tag (configured or learned)
+func (m *Monitor) interval(tag string, s *tagState) time.Duration {
+	if d, ok := m.options.Intervals[tag]; ok {
+		return d
+	}
+	if m.options.Interval > 0 {
+		return m.options.Interval
+	}
+	return s.learned
+}
+
+func (m *Monitor) newEvent(eventType string, tag string, s *tagState, ts time.Time) *Event {
+	return &Event{
+		Type:            eventType,
+		TagMAC:          tag,
+		TS:              ts,
+		LastSeen:        s.lastSeen,
+		IntervalSeconds: m.interval(tag, s).Seconds(),
+		SilentSeconds:   ts.Sub(s.lastSeen).Seconds(),
+		Misses:          m.options.Misses,
+	}
+}
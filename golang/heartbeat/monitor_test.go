@@ -0,0 +1,88 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLearnedInterval(t *testing.T) {
+	m := NewMonitor(&MonitorOptions{Misses: 3, CheckInterval: time.Hour})
+	defer m.Close()
+
+	// report every 60 seconds
+	start := time.Unix(1700000000, 0)
+	tag := "11:22:33:44:55:66"
+	for i := 0; i < 5; i++ {
+		m.Seen(tag, start.Add(time.Duration(i)*time.Minute))
+	}
+	last := start.Add(4 * time.Minute)
+
+	// the tag is not silent until it misses 3 intervals
+	if events := m.Check(last.Add(150 * time.Second)); len(events) != 0 {
+		t.Fatalf("events = %+v, want none", events)
+	}
+	events := m.Check(last.Add(181 * time.Second))
+	if len(events) != 1 || events[0].Type != TagSilentEventType || events[0].IntervalSeconds != 60 {
+		t.Fatalf("events = %+v, want one silent event", events)
+	}
+
+	// the silent event is only published once
+	if events := m.Check(last.Add(time.Hour)); len(events) != 0 {
+		t.Fatalf("events = %+v, want none", events)
+	}
+
+	// the tag recovers with the next packet (and the outage is not learned)
+	e := m.Seen(tag, last.Add(2*time.Hour))
+	if e == nil || e.Type != TagRecoveredEventType || e.IntervalSeconds != 60 {
+		t.Fatalf("event = %+v, want a recovered event", e)
+	}
+}
+
+func TestConfiguredInterval(t *testing.T) {
+	m := NewMonitor(&MonitorOptions{
+		Misses:        2,
+		CheckInterval: time.Hour,
+		Intervals:     map[string]time.Duration{"112233445566": 10 * time.Second},
+	})
+	defer m.Close()
+
+	// the configured interval applies from the first packet
+	start := time.Unix(1700000000, 0)
+	m.Seen("11:22:33:44:55:66", start)
+	m.Seen("AA:BB:CC:DD:EE:FF", start)
+	events := m.Check(start.Add(21 * time.Second))
+	if len(events) != 1 || events[0].TagMAC != "11:22:33:44:55:66" {
+		t.Fatalf("events = %+v, want one silent event", events)
+	}
+}
+
+func TestConfiguredTagNeverSeen(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	options := &MonitorOptions{
+		CheckInterval: time.Hour,
+		Intervals:     map[string]time.Duration{"112233445566": 10 * time.Second},
+		Start:         start,
+	}
+	m := NewMonitor(options)
+	defer m.Close()
+
+	// the given options are not modified
+	if options.Misses != 0 || options.Intervals["112233445566"] != 10*time.Second {
+		t.Fatalf("options = %+v", options)
+	}
+
+	// a configured tag that never reports is silent once it misses the intervals from the start
+	if events := m.Check(start.Add(29 * time.Second)); len(events) != 0 {
+		t.Fatalf("events = %+v, want none", events)
+	}
+	events := m.Check(start.Add(31 * time.Second))
+	if len(events) != 1 || events[0].TagMAC != "11:22:33:44:55:66" || !events[0].LastSeen.Equal(start) {
+		t.Fatalf("events = %+v, want one silent event", events)
+	}
+
+	// the tag recovers with its first packet
+	e := m.Seen("11:22:33:44:55:66", start.Add(time.Minute))
+	if e == nil || e.Type != TagRecoveredEventType {
+		t.Fatalf("event = %+v, want a recovered event", e)
+	}
+}
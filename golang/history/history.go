@@ -0,0 +1,272 @@
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// history default option values
+const (
+	DefaultFlushInterval = 1 * time.Second
+	DefaultPruneInterval = 1 * time.Hour
+	recordChannelSize    = 4096
+	maxBatchSize         = 500
+)
+
+// schema defines the history database tables and indices
+const schema = `
+CREATE TABLE IF NOT EXISTS tags (
+	tag_mac    TEXT PRIMARY KEY,
+	first_seen INTEGER NOT NULL,
+	last_seen  INTEGER NOT NULL,
+	ap_mac     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS packets (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	ts                INTEGER NOT NULL,
+	tag_mac           TEXT NOT NULL,
+	ap_mac            TEXT NOT NULL,
+	remote_ip         TEXT NOT NULL,
+	remote_port       INTEGER NOT NULL,
+	sequence          INTEGER NOT NULL,
+	tx_power          INTEGER NOT NULL,
+	channel           INTEGER NOT NULL,
+	burst_length      INTEGER NOT NULL,
+	product_type      INTEGER NOT NULL,
+	battery_charge    INTEGER NOT NULL,
+	battery_tolerance INTEGER NOT NULL,
+	battery_days      INTEGER NOT NULL,
+	battery_age       INTEGER NOT NULL,
+	raw               BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS telemetry (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	packet_id INTEGER NOT NULL REFERENCES packets(id),
+	ts        INTEGER NOT NULL,
+	tag_mac   TEXT NOT NULL,
+	type      INTEGER NOT NULL,
+	celsius   REAL,
+	status    TEXT
+);
+CREATE INDEX IF NOT EXISTS packets_tag_ts ON packets (tag_mac, ts);
+CREATE INDEX IF NOT EXISTS packets_ts ON packets (ts);
+CREATE INDEX IF NOT EXISTS telemetry_tag_ts ON telemetry (tag_mac, ts);
+CREATE INDEX IF NOT EXISTS telemetry_ts ON telemetry (ts);
+CREATE INDEX IF NOT EXISTS telemetry_packet ON telemetry (packet_id);
+`
+
+// History writes every decoded packet to an embedded sqlite database
+type History struct {
+	options *HistoryOptions
+	db      *sql.DB
+	records chan *record
+	wg      sync.WaitGroup
+}
+
+// HistoryOptions provides the instance options
+type HistoryOptions struct {
+	Path          string
+	Retention     time.Duration
+	FlushInterval time.Duration
+	PruneInterval time.Duration
+}
+
+// record defines a single packet queued to be written
+type record struct {
+	du     *udp.DataUpdate
+	packet *ccx.DecodedPacket
+}
+
+// NewHistory opens (or creates) the database and starts the background writer goroutine
+func NewHistory(options *HistoryOptions) (*History, error) {
+	// apply the default option values
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = DefaultFlushInterval
+	}
+	if options.PruneInterval <= 0 {
+		options.PruneInterval = DefaultPruneInterval
+	}
+
+	// if the sqlite driver does not support this platform
+	if !sqliteSupported {
+		return nil, fmt.Errorf("The history database is not supported on '%s/%s'", runtime.GOOS, runtime.GOARCH)
+	}
+
+	// open the database (a single connection serializes every write)
+	db, err := sql.Open("sqlite", options.Path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	// enable write-ahead logging and create the schema
+	if _, err := db.Exec("PRAGMA journal_mode=WAL; PRAGMA busy_timeout=5000;" + schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Cannot create history database schema in '%s' (%v)", options.Path, err)
+	}
+
+	// create the new instance
+	h := &History{
+		options: options,
+		db:      db,
+		records: make(chan *record, recordChannelSize),
+	}
+
+	// remove any expired history and start the writer goroutine
+	h.prune()
+	h.wg.Add(1)
+	go h.run()
+
+	// return the new instance
+	return h, nil
+}
+
+// DB returns the underlying database (e.g. for queries)
+func (h *History) DB() *sql.DB {
+	return h.db
+}
+
+// Record queues the given received and decoded packet to be written
+func (h *History) Record(du *udp.DataUpdate, packet *ccx.DecodedPacket) error {
+	select {
+	case h.records <- &record{du: du, packet: packet}:
+		return nil
+	default:
+		return fmt.Errorf("History write queue is full (dropping packet from '%s')", packet.TagMAC())
+	}
+}
+
+// Close writes any queued packets and closes the database
+func (h *History) Close() error {
+	close(h.records)
+	h.wg.Wait()
+	return h.db.Close()
+}
+
+func (h *History) run() {
+	defer h.wg.Done()
+
+	// create the flush and retention tickers
+	flushTicker := time.NewTicker(h.options.FlushInterval)
+	defer flushTicker.Stop()
+	pruneTicker := time.NewTicker(h.options.PruneInterval)
+	defer pruneTicker.Stop()
+
+	// write the queued records in batches until closed
+	batch := []*record{}
+	for {
+		select {
+		case r, ok := <-h.records:
+			// if closed, write the final batch and return now
+			if !ok {
+				h.write(batch)
+				return
+			}
+
+			// write the batch when full
+			batch = append(batch, r)
+			if len(batch) >= maxBatchSize {
+				h.write(batch)
+				batch = []*record{}
+			}
+
+		case <-flushTicker.C:
+			h.write(batch)
+			batch = []*record{}
+
+		case <-pruneTicker.C:
+			h.prune()
+		}
+	}
+}
+
+func (h *History) write(batch []*record) {
+	// if there is nothing to write
+	if len(batch) == 0 {
+		return
+	}
+
+	// write the whole batch in a single transaction
+	if err := h.writeTx(batch); err != nil {
+		fmt.Printf("Error writing %d packets to history database '%s' (error = '%v')\n",
+			len(batch), h.options.Path, err)
+	}
+}
+
+func (h *History) writeTx(batch []*record) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range batch {
+		p := r.packet
+		ts := r.du.TS.UnixNano()
+
+		// insert the packet row
+		result, err := tx.Exec(`INSERT INTO packets (ts, tag_mac, ap_mac, remote_ip, remote_port, sequence,
+			tx_power, channel, burst_length, product_type, battery_charge, battery_tolerance, battery_days,
+			battery_age, raw) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ts, p.TagMAC(), p.APMAC(), r.du.RemoteIP, r.du.RemotePort, p.EmanateHeader.Sequence,
+			p.Header.Power, p.Header.Channel, p.Header.Burst, p.System.ProductType, p.BatteryCharge(),
+			p.BatteryTolerance(), p.Battery.Days, p.Battery.Age, r.du.Data)
+		if err != nil {
+			return err
+		}
+		packetID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		// insert a row for each temperature and status telemetry entry
+		for _, t := range p.Telemetry {
+			var celsius, status interface{}
+			switch {
+			case t.GroupID != ccx.TelemetryGroupID:
+				continue
+			case t.Type == ccx.TemperatureTelemetryType:
+				celsius = t.Celsius
+			case t.Type == ccx.StatusTelemetryType:
+				status = t.Status
+			default:
+				continue
+			}
+			if _, err := tx.Exec(`INSERT INTO telemetry (packet_id, ts, tag_mac, type, celsius, status)
+				VALUES (?, ?, ?, ?, ?, ?)`, packetID, ts, p.TagMAC(), t.Type, celsius, status); err != nil {
+				return err
+			}
+		}
+
+		// insert or update the tag row
+		if _, err := tx.Exec(`INSERT INTO tags (tag_mac, first_seen, last_seen, ap_mac) VALUES (?, ?, ?, ?)
+			ON CONFLICT (tag_mac) DO UPDATE SET last_seen = excluded.last_seen, ap_mac = excluded.ap_mac`,
+			p.TagMAC(), ts, ts, p.APMAC()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (h *History) prune() {
+	// if the history is kept forever
+	if h.options.Retention <= 0 {
+		return
+	}
+
+	// delete the packets and telemetry older than the retention period
+	cutoff := time.Now().Add(-h.options.Retention).UnixNano()
+	for _, table := range []string{"telemetry", "packets"} {
+		if _, err := h.db.Exec("DELETE FROM "+table+" WHERE ts < ?", cutoff); err != nil {
+			fmt.Printf("Error pruning '%s' history older than '%s' (error = '%v')\n",
+				table, h.options.Retention, err)
+		}
+	}
+}
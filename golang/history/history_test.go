@@ -0,0 +1,80 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// count returns the number of rows of the given query
+func count(t *testing.T, h *History, query string, args ...interface{}) int {
+	n := 0
+	if err := h.DB().QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestHistory(t *testing.T) {
+	if !sqliteSupported {
+		t.Skip("the sqlite driver does not support this platform")
+	}
+
+	// open a new database keeping a day of history
+	h, err := NewHistory(&HistoryOptions{
+		Path:          filepath.Join(t.TempDir(), "history.db"),
+		Retention:     24 * time.Hour,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// record a packet from two days ago and a recent packet
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-48 * time.Hour), now} {
+		p, _ := ccx.NewBuilder().WithTagMAC("11:22:33:44:55:66").WithTemperature(21.5).
+			WithUtilState("idle").Build()
+		data, _ := p.Pack()
+		packet, err := ccx.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Record(&udp.DataUpdate{TS: ts, RemoteIP: "10.0.0.7", Data: data}, packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// wait for the packets to be written
+	deadline := time.Now().Add(5 * time.Second)
+	for count(t, h, "SELECT COUNT(*) FROM packets") < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the packet, telemetry, and tag rows are queryable
+	if n := count(t, h, "SELECT COUNT(*) FROM packets WHERE tag_mac = ?", "11:22:33:44:55:66"); n != 2 {
+		t.Fatalf("packets = %d, want 2", n)
+	}
+	if n := count(t, h, "SELECT COUNT(*) FROM telemetry WHERE celsius = 21.5"); n != 2 {
+		t.Errorf("temperature rows = %d, want 2", n)
+	}
+	if n := count(t, h, "SELECT COUNT(*) FROM telemetry WHERE status = ?", ccx.UtilStatePluggedInIdle); n != 2 {
+		t.Errorf("status rows = %d, want 2", n)
+	}
+	if n := count(t, h, "SELECT COUNT(*) FROM tags WHERE last_seen = ?", now.UnixNano()); n != 1 {
+		t.Errorf("tags = %d, want 1", n)
+	}
+
+	// the history older than the retention period is pruned
+	h.prune()
+	if n := count(t, h, "SELECT COUNT(*) FROM packets"); n != 1 {
+		t.Errorf("packets after prune = %d, want 1", n)
+	}
+	if n := count(t, h, "SELECT COUNT(*) FROM telemetry"); n != 2 {
+		t.Errorf("telemetry rows after prune = %d, want 2", n)
+	}
+}
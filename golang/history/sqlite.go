@@ -0,0 +1,11 @@
+//go:build (darwin && (amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (linux && (386 || amd64 || arm || arm64 || ppc64le || riscv64 || s390x)) || (netbsd && amd64) || (openbsd && (amd64 || arm64)) || (windows && (amd64 || arm64))
+
+package history
+
+import (
+	// register the pure go sqlite database driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSupported is whether the sqlite driver supports this platform
+const sqliteSupported = true
@@ -0,0 +1,7 @@
+//go:build !((darwin && (amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (linux && (386 || amd64 || arm || arm64 || ppc64le || riscv64 || s390x)) || (netbsd && amd64) || (openbsd && (amd64 || arm64)) || (windows && (amd64 || arm64)))
+
+package history
+
+// sqliteSupported is whether the sqlite driver supports this platform (the pure go driver is not
+// available on every platform, e.g. windows/386)
+const sqliteSupported = false
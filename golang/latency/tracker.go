@@ -0,0 +1,179 @@
+package latency
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets defines the default latency histogram bucket upper bounds
+var DefaultBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// Tracker accumulates the end-to-end latency histograms of each tag
+type Tracker struct {
+	mutex      sync.Mutex
+	buckets    []time.Duration
+	histograms map[string]*Histogram
+}
+
+// Histogram defines the latency histogram of a single tag
+type Histogram struct {
+	Count      int
+	Sum        time.Duration
+	Min        time.Duration
+	Max        time.Duration
+	Counts     []int
+	OutOfOrder int
+	Buckets    []time.Duration
+
+	// the latest send timestamp received (a packet sent before it arrived out of order)
+	lastSent time.Time
+}
+
+// NewTracker creates a new instance using the default histogram buckets
+func NewTracker() *Tracker {
+	// return the new instance
+	return &Tracker{
+		buckets:    DefaultBuckets,
+		histograms: map[string]*Histogram{},
+	}
+}
+
+// Record adds the latency sample of a packet of the given tag sent and received at the given times
+// to the histogram of the tag, and returns the latency
+func (t *Tracker) Record(tagMAC string, sent time.Time, received time.Time) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// get the tag histogram (or create it if this is the first sample)
+	d := received.Sub(sent)
+	h, ok := t.histograms[tagMAC]
+	if !ok {
+		h = &Histogram{
+			Min:      d,
+			Max:      d,
+			Counts:   make([]int, len(t.buckets)+1),
+			Buckets:  t.buckets,
+			lastSent: sent,
+		}
+		t.histograms[tagMAC] = h
+	}
+
+	// count the packets sent before a packet already received
+	if sent.Before(h.lastSent) {
+		h.OutOfOrder++
+	} else {
+		h.lastSent = sent
+	}
+
+	// update the summary values
+	h.Count++
+	h.Sum += d
+	if d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+
+	// find the bucket of the sample (the last bucket is the overflow bucket)
+	i := sort.Search(len(t.buckets), func(i int) bool { return d <= t.buckets[i] })
+	h.Counts[i]++
+	return d
+}
+
+// Histogram returns a copy of the latency histogram of the given tag (or nil if no samples were
+// recorded)
+func (t *Tracker) Histogram(tagMAC string) *Histogram {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	h, ok := t.histograms[tagMAC]
+	if !ok {
+		return nil
+	}
+	copied := *h
+	copied.Counts = append([]int{}, h.Counts...)
+	return &copied
+}
+
+// Percentile returns the upper bound of the bucket holding the given percentile (0-100) of the
+// samples, limited to the max sample (so the overflow bucket percentiles are the max)
+func (h *Histogram) Percentile(p float64) time.Duration {
+	// if no samples have been recorded
+	if h.Count == 0 {
+		return 0
+	}
+
+	// find the bucket of the sample ranked at the percentile
+	rank := int(math.Ceil(p / 100 * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for i, n := range h.Counts {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		if i >= len(h.Buckets) || h.Buckets[i] > h.Max {
+			return h.Max
+		}
+		return h.Buckets[i]
+	}
+	return h.Max
+}
+
+// Dump dumps the latency histogram of every tag to the console
+func (t *Tracker) Dump() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// if no samples have been recorded yet
+	if len(t.histograms) == 0 {
+		return
+	}
+
+	// sort the tags so the output order is stable
+	tags := []string{}
+	for tag := range t.histograms {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	fmt.Printf("\nLATENCY HISTOGRAMS (TEST-ONLY)\n")
+	fmt.Printf("==============================\n\n")
+	for _, tag := range tags {
+		h := t.histograms[tag]
+		fmt.Printf("  - Tag MAC = %s\n", tag)
+		fmt.Printf("    - Samples = %d\n", h.Count)
+		fmt.Printf("    - Min = %s\n", h.Min)
+		fmt.Printf("    - Avg = %s\n", h.Sum/time.Duration(h.Count))
+		fmt.Printf("    - Max = %s\n", h.Max)
+		fmt.Printf("    - P50 = %s\n", h.Percentile(50))
+		fmt.Printf("    - P95 = %s\n", h.Percentile(95))
+		fmt.Printf("    - P99 = %s\n", h.Percentile(99))
+		fmt.Printf("    - Out Of Order = %d\n", h.OutOfOrder)
+		for i, n := range h.Counts {
+			if i < len(t.buckets) {
+				fmt.Printf("    - <= %s = %d\n", t.buckets[i], n)
+			} else {
+				fmt.Printf("    - >  %s = %d\n", t.buckets[i-1], n)
+			}
+		}
+	}
+	fmt.Println("")
+}
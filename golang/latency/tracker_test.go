@@ -0,0 +1,76 @@
+package latency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	tracker := NewTracker()
+	sent := time.Now()
+
+	// 90 samples of 3ms, 9 samples of 40ms, and 1 sample of 2s (in the overflow bucket)
+	for i := 0; i < 100; i++ {
+		d := 3 * time.Millisecond
+		if i >= 90 {
+			d = 40 * time.Millisecond
+		}
+		if i == 99 {
+			d = 2 * time.Second
+		}
+		sent = sent.Add(time.Second)
+		if latency := tracker.Record("tag", sent, sent.Add(d)); latency != d {
+			t.Fatalf("Record() = %s, expected %s", latency, d)
+		}
+	}
+
+	// the percentiles are the upper bounds of their buckets (the overflow bucket is the max)
+	h := tracker.Histogram("tag")
+	for _, test := range []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0, 5 * time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{90, 5 * time.Millisecond},
+		{95, 50 * time.Millisecond},
+		{99, 50 * time.Millisecond},
+		{100, 2 * time.Second},
+	} {
+		if d := h.Percentile(test.p); d != test.expected {
+			t.Errorf("Percentile(%v) = %s, expected %s", test.p, d, test.expected)
+		}
+	}
+	if h.Count != 100 || h.Min != 3*time.Millisecond || h.Max != 2*time.Second || h.OutOfOrder != 0 {
+		t.Errorf("histogram = %+v", h)
+	}
+
+	// the bucket bound is limited to the max sample
+	tracker.Record("single", sent, sent.Add(300*time.Millisecond))
+	if d := tracker.Histogram("single").Percentile(50); d != 300*time.Millisecond {
+		t.Errorf("Percentile(50) = %s", d)
+	}
+	if tracker.Histogram("unknown") != nil || (&Histogram{}).Percentile(50) != 0 {
+		t.Error("unexpected histogram")
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	tracker := NewTracker()
+	base := time.Now()
+
+	// the packets sent before the latest received packet are counted (a repeated send timestamp is not)
+	for _, offset := range []int{1, 2, 5, 3, 4, 5, 6, 1} {
+		sent := base.Add(time.Duration(offset) * time.Second)
+		tracker.Record("tag", sent, sent.Add(time.Millisecond))
+	}
+	if h := tracker.Histogram("tag"); h.Count != 8 || h.OutOfOrder != 3 {
+		t.Errorf("histogram = %+v", h)
+	}
+
+	// the tags are tracked separately
+	tracker.Record("other", base, base.Add(time.Millisecond))
+	if h := tracker.Histogram("other"); h.OutOfOrder != 0 {
+		t.Errorf("histogram = %+v", h)
+	}
+}
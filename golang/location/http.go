@@ -0,0 +1,34 @@
+package location
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// Handler returns the http handler serving the 'GET /locations' and 'GET /locations/{mac}' json endpoints
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the GET method is supported
+		if r.Method != http.MethodGet {
+			util.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		// if no tag mac-address is given, return the current location of every tag
+		mac := strings.Trim(strings.TrimPrefix(r.URL.Path, "/locations"), "/")
+		if mac == "" {
+			util.WriteJSON(w, http.StatusOK, t.All())
+			return
+		}
+
+		// return the location history of the given tag
+		history, ok := t.History(util.NormalizeMAC(mac))
+		if !ok {
+			util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "tag '" + mac + "' not found"})
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, history)
+	})
+}
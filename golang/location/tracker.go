@@ -0,0 +1,194 @@
+package location
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// location default constants
+const (
+	DefaultHistorySize = 100
+	RoamEventType      = "roam"
+)
+
+// Tracker keeps the access point location history of every tag
+type Tracker struct {
+	mutex   sync.Mutex
+	options *TrackerOptions
+	tags    map[string][]*Visit
+}
+
+// TrackerOptions provides the instance options
+type TrackerOptions struct {
+	// APMap maps access point mac-addresses to location names (e.g. floor or room)
+	APMap map[string]string
+
+	// HistorySize is the number of visits kept for each tag
+	HistorySize int
+}
+
+// Visit defines a period of time a tag was reported by a single access point
+type Visit struct {
+	APMAC     string    `json:"ap_mac"`
+	Location  string    `json:"location"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Event defines a tag moving between access points
+type Event struct {
+	Type         string    `json:"type"`
+	TagMAC       string    `json:"tag_mac"`
+	TS           time.Time `json:"ts"`
+	FromAPMAC    string    `json:"from_ap_mac"`
+	FromLocation string    `json:"from_location"`
+	ToAPMAC      string    `json:"to_ap_mac"`
+	ToLocation   string    `json:"to_location"`
+	Message      string    `json:"message"`
+}
+
+// TagLocation defines the current location of a single tag
+type TagLocation struct {
+	TagMAC string `json:"tag_mac"`
+	Visit
+}
+
+// LoadAPMap reads the given json file mapping access point mac-addresses to location names
+func LoadAPMap(path string) (map[string]string, error) {
+	// read the map file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse the map
+	apMap := map[string]string{}
+	if err := json.Unmarshal(data, &apMap); err != nil {
+		return nil, fmt.Errorf("Invalid access point map file '%s' (%v)", path, err)
+	}
+
+	// return the map
+	return apMap, nil
+}
+
+// NewTracker creates a new instance
+func NewTracker(options *TrackerOptions) *Tracker {
+	// apply the default option values
+	if options.HistorySize <= 0 {
+		options.HistorySize = DefaultHistorySize
+	}
+
+	// normalize the access point mac-addresses of the map
+	apMap := map[string]string{}
+	for ap, name := range options.APMap {
+		apMap[util.NormalizeMAC(ap)] = name
+	}
+	options.APMap = apMap
+
+	// return the new instance
+	return &Tracker{
+		options: options,
+		tags:    map[string][]*Visit{},
+	}
+}
+
+// Update records the access point that reported the given tag at the given time and returns the
+// roam event if the tag moved from another access point (or nil)
+func (t *Tracker) Update(tagMAC string, apMAC string, ts time.Time) *Event {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// if the tag is still at the same access point, extend the current visit
+	visits := t.tags[tagMAC]
+	var last *Visit
+	if len(visits) > 0 {
+		last = visits[len(visits)-1]
+		if last.APMAC == apMAC {
+			if ts.After(last.LastSeen) {
+				last.LastSeen = ts
+			}
+			return nil
+		}
+	}
+
+	// start a new visit (dropping the oldest visit if the history is full)
+	visit := &Visit{
+		APMAC:     apMAC,
+		Location:  t.Location(apMAC),
+		FirstSeen: ts,
+		LastSeen:  ts,
+	}
+	visits = append(visits, visit)
+	if len(visits) > t.options.HistorySize {
+		visits = visits[len(visits)-t.options.HistorySize:]
+	}
+	t.tags[tagMAC] = visits
+
+	// the first visit of a tag is not a roam
+	if last == nil {
+		return nil
+	}
+
+	// return the roam event
+	return &Event{
+		Type:         RoamEventType,
+		TagMAC:       tagMAC,
+		TS:           ts,
+		FromAPMAC:    last.APMAC,
+		FromLocation: last.Location,
+		ToAPMAC:      visit.APMAC,
+		ToLocation:   visit.Location,
+		Message:      fmt.Sprintf("Tag '%s' moved from %s to %s", tagMAC, last.Location, visit.Location),
+	}
+}
+
+// Location returns the location name of the given access point (or the mac-address if not mapped)
+func (t *Tracker) Location(apMAC string) string {
+	if name, ok := t.options.APMap[apMAC]; ok {
+		return name
+	}
+	return apMAC
+}
+
+// History returns the visits of the given tag, oldest first
+func (t *Tracker) History(tagMAC string) ([]Visit, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// copy the visits of the tag
+	visits, ok := t.tags[tagMAC]
+	if !ok {
+		return nil, false
+	}
+	history := make([]Visit, len(visits))
+	for i, v := range visits {
+		history[i] = *v
+	}
+	return history, true
+}
+
+// All returns the current location of every tag, sorted by tag mac-address
+func (t *Tracker) All() []TagLocation {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// copy the last visit of every tag
+	locations := []TagLocation{}
+	for tag, visits := range t.tags {
+		locations = append(locations, TagLocation{TagMAC: tag, Visit: *visits[len(visits)-1]})
+	}
+
+	// sort the locations by tag mac-address
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].TagMAC < locations[j].TagMAC
+	})
+
+	// return the locations
+	return locations
+}
@@ -0,0 +1,41 @@
+package location
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoamEvents(t *testing.T) {
+	tracker := NewTracker(&TrackerOptions{
+		APMap:       map[string]string{"aa-aa-aa-aa-aa-01": "3W", "AAAAAAAAAA02": "OR-2"},
+		HistorySize: 2,
+	})
+	tag := "11:22:33:44:55:66"
+	start := time.Unix(1700000000, 0)
+
+	// the first report and the reports from the same access point are not roams
+	if e := tracker.Update(tag, "AA:AA:AA:AA:AA:01", start); e != nil {
+		t.Fatalf("event = %+v, want nil", e)
+	}
+	if e := tracker.Update(tag, "AA:AA:AA:AA:AA:01", start.Add(time.Minute)); e != nil {
+		t.Fatalf("event = %+v, want nil", e)
+	}
+
+	// moving to another access point is a roam
+	e := tracker.Update(tag, "AA:AA:AA:AA:AA:02", start.Add(2*time.Minute))
+	if e == nil || e.Message != "Tag '11:22:33:44:55:66' moved from 3W to OR-2" {
+		t.Fatalf("event = %+v", e)
+	}
+
+	// unmapped access points use the mac-address as the location
+	e = tracker.Update(tag, "AA:AA:AA:AA:AA:03", start.Add(3*time.Minute))
+	if e == nil || e.FromLocation != "OR-2" || e.ToLocation != "AA:AA:AA:AA:AA:03" {
+		t.Fatalf("event = %+v", e)
+	}
+
+	// only the last visits are kept
+	history, _ := tracker.History(tag)
+	if len(history) != 2 || history[0].Location != "OR-2" || history[1].APMAC != "AA:AA:AA:AA:AA:03" {
+		t.Fatalf("history = %+v", history)
+	}
+}
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/dedup"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// metrics default option values
+const (
+	DefaultActiveWindow = 10 * time.Minute
+	DefaultTagExpiry    = time.Hour
+	pruneInterval       = time.Minute
+)
+
+// Metrics collects the receiver prometheus metrics
+type Metrics struct {
+	options   *MetricsOptions
+	mutex     sync.Mutex
+	registry  *prometheus.Registry
+	tags      map[string]time.Time
+	lastPrune time.Time
+
+	packets      prometheus.Counter
+	bytes        prometheus.Counter
+	decodeErrors *prometheus.CounterVec
+	duplicates   prometheus.Counter
+	dropped      *prometheus.CounterVec
+	sequenceGaps prometheus.Counter
+	temperature  *prometheus.GaugeVec
+	charge       *prometheus.GaugeVec
+	utilState    *prometheus.GaugeVec
+	lastSeen     *prometheus.GaugeVec
+}
+
+// MetricsOptions provides the instance options
+type MetricsOptions struct {
+	// ActiveWindow is the time a tag is counted as active after its last packet
+	ActiveWindow time.Duration
+
+	// TagExpiry is the time after its last packet before the per-tag metrics of a tag are removed
+	TagExpiry time.Duration
+}
+
+// NewMetrics creates a new instance and registers every metric
+func NewMetrics(options *MetricsOptions) *Metrics {
+	// apply the default option values
+	if options.ActiveWindow <= 0 {
+		options.ActiveWindow = DefaultActiveWindow
+	}
+	if options.TagExpiry <= 0 {
+		options.TagExpiry = DefaultTagExpiry
+	}
+
+	// create the new instance
+	m := &Metrics{
+		options:  options,
+		registry: prometheus.NewRegistry(),
+		tags:     map[string]time.Time{},
+		packets: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "emanate_packets_received_total",
+			Help: "Number of udp packets received.",
+		}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "emanate_bytes_received_total",
+			Help: "Number of udp payload bytes received.",
+		}),
+		decodeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "emanate_decode_errors_total",
+			Help: "Number of udp packets that could not be decoded, by error type.",
+		}, []string{"type"}),
+		duplicates: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "emanate_duplicates_suppressed_total",
+			Help: "Number of duplicate burst copies (same tag and sequence number) received.",
+		}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "emanate_packets_dropped_total",
+			Help: "Number of udp packets dropped by the source filters, rate limits, or packet authentication, by reason.",
+		}, []string{"reason"}),
+		sequenceGaps: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "emanate_sequence_gaps_total",
+			Help: "Number of missing sequence numbers detected across all tags.",
+		}),
+		temperature: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "emanate_tag_temperature_celsius",
+			Help: "Last reported temperature of each tag.",
+		}, []string{"tag"}),
+		charge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "emanate_tag_battery_charge_percent",
+			Help: "Last reported battery charge percentage of each tag.",
+		}, []string{"tag"}),
+		utilState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "emanate_tag_util_state",
+			Help: "Last reported utility state of each tag (1 for the current state, 0 otherwise).",
+		}, []string{"tag", "state"}),
+		lastSeen: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "emanate_tag_last_seen_timestamp_seconds",
+			Help: "Unix time of the last packet received from each tag.",
+		}, []string{"tag"}),
+	}
+
+	// register every metric (plus the standard go runtime and process metrics)
+	m.registry.MustRegister(
+		m.packets,
+		m.bytes,
+		m.decodeErrors,
+		m.duplicates,
+		m.dropped,
+		m.sequenceGaps,
+		m.temperature,
+		m.charge,
+		m.utilState,
+		m.lastSeen,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "emanate_active_tags",
+			Help: "Number of tags that sent a packet within the active window.",
+		}, m.activeTags),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	// return the new instance
+	return m
+}
+
+// Handler returns the http handler serving the metrics in the prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Dropped counts a udp packet dropped by the receiver for the given reason
+func (m *Metrics) Dropped(du *udp.DataUpdate, reason string) {
+	m.dropped.WithLabelValues(reason).Inc()
+}
+
+// Observe updates the metrics with the given received packet, its decode result, and its
+// duplicate check result
+func (m *Metrics) Observe(du *udp.DataUpdate, packet *ccx.DecodedPacket, err error, check dedup.Result) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// remove the per-tag metrics of the expired tags (at most once per prune interval)
+	if du.TS.Sub(m.lastPrune) >= pruneInterval {
+		m.prune(du.TS)
+	}
+
+	// count the received packet
+	m.packets.Inc()
+	m.bytes.Add(float64(len(du.Data)))
+
+	// count the decode error by type (malformed packets do not update the tag metrics)
+	if err != nil {
+		m.decodeErrors.WithLabelValues(DecodeErrorType(err)).Inc()
+		return
+	}
+
+	// record when the tag was last seen
+	tag := packet.TagMAC()
+	m.tags[tag] = du.TS
+	m.lastSeen.WithLabelValues(tag).Set(float64(du.TS.UnixNano()) / 1e9)
+
+	// if the packet is a duplicate burst copy
+	if check.Duplicate {
+		m.duplicates.Inc()
+		return
+	}
+
+	// count any skipped sequence numbers
+	if check.Missed > 0 {
+		m.sequenceGaps.Add(float64(check.Missed))
+	}
+
+	// update the per-tag telemetry gauges
+	m.charge.WithLabelValues(tag).Set(float64(packet.BatteryCharge()))
+	if tempC, ok := packet.Temperature(); ok {
+		m.temperature.WithLabelValues(tag).Set(float64(tempC))
+	}
+	if current, ok := packet.UtilState(); ok {
+		for _, name := range ccx.UtilStateNames {
+			v := 0.0
+			if name == current {
+				v = 1.0
+			}
+			m.utilState.WithLabelValues(tag, name).Set(v)
+		}
+	}
+}
+
+func (m *Metrics) prune(now time.Time) {
+	// remove the tags (and their labelled gauges) not seen within the expiry
+	for tag, lastSeen := range m.tags {
+		if now.Sub(lastSeen) > m.options.TagExpiry {
+			delete(m.tags, tag)
+			labels := prometheus.Labels{"tag": tag}
+			m.temperature.DeletePartialMatch(labels)
+			m.charge.DeletePartialMatch(labels)
+			m.utilState.DeletePartialMatch(labels)
+			m.lastSeen.DeletePartialMatch(labels)
+		}
+	}
+	m.lastPrune = now
+}
+
+func (m *Metrics) activeTags() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// count the tags seen within the active window
+	n := 0
+	for _, lastSeen := range m.tags {
+		if time.Since(lastSeen) <= m.options.ActiveWindow {
+			n++
+		}
+	}
+	return float64(n)
+}
+
+// DecodeErrorType returns the metric label of the given decode error
+func DecodeErrorType(err error) string {
+	switch {
+	case errors.Is(err, ccx.ErrTruncatedPacket):
+		return "truncated_packet"
+	case errors.Is(err, ccx.ErrTruncatedGroup):
+		return "truncated_group"
+	case errors.Is(err, ccx.ErrMalformedTemperature):
+		return "malformed_temperature"
+	case errors.Is(err, ccx.ErrMalformedStatus):
+		return "malformed_status"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/dedup"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// observe decodes a packet of the given tag and sequence number received at the given time, and
+// updates the metrics with it
+func observe(t *testing.T, m *Metrics, d *dedup.Detector, tag string, seq uint16, ts time.Time) {
+	p, err := ccx.NewBuilder().WithTagMAC(tag).WithSequence(seq).WithTemperature(21.5).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := p.Pack()
+	packet, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Observe(&udp.DataUpdate{TS: ts, Data: data}, packet, nil, d.Check(packet.TagMAC(), seq, ts))
+}
+
+// scrape returns the metrics in the prometheus text format
+func scrape(m *Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestObserve(t *testing.T) {
+	m := NewMetrics(&MetricsOptions{})
+	d := dedup.NewDetector(&dedup.DetectorOptions{})
+	ts := time.Now()
+
+	// a packet, its burst copy, and a packet after a gap of 2 sequence numbers
+	observe(t, m, d, "11:22:33:44:55:66", 1, ts)
+	observe(t, m, d, "11:22:33:44:55:66", 1, ts)
+	observe(t, m, d, "11:22:33:44:55:66", 4, ts)
+	m.Observe(&udp.DataUpdate{TS: ts, Data: []byte{0x00}}, nil, ccx.ErrTruncatedPacket, dedup.Result{})
+
+	text := scrape(m)
+	for _, line := range []string{
+		"emanate_packets_received_total 4",
+		"emanate_duplicates_suppressed_total 1",
+		"emanate_sequence_gaps_total 2",
+		`emanate_decode_errors_total{type="truncated_packet"} 1`,
+		`emanate_tag_temperature_celsius{tag="11:22:33:44:55:66"} 21.5`,
+		"emanate_active_tags 1",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing metric %q", line)
+		}
+	}
+}
+
+func TestTagExpiry(t *testing.T) {
+	m := NewMetrics(&MetricsOptions{TagExpiry: 10 * time.Minute})
+	d := dedup.NewDetector(&dedup.DetectorOptions{})
+	ts := time.Now()
+
+	// the per-tag series of the tags not seen within the expiry are removed
+	observe(t, m, d, "11:22:33:44:55:66", 1, ts)
+	observe(t, m, d, "AA:BB:CC:DD:EE:FF", 1, ts.Add(11*time.Minute))
+	text := scrape(m)
+	if strings.Contains(text, `tag="11:22:33:44:55:66"`) {
+		t.Error("expired tag series were not removed")
+	}
+	if !strings.Contains(text, `emanate_tag_temperature_celsius{tag="AA:BB:CC:DD:EE:FF"} 21.5`) {
+		t.Error("missing active tag series")
+	}
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// message type constants
+const (
+	PacketMessageType       = "packet"
+	AlertMessageType        = "alert"
+	TagSilentMessageType    = "tag_silent"
+	TagRecoveredMessageType = "tag_recovered"
+	RoamMessageType         = "roam"
+	LocationMessageType     = "location"
+)
+
+// Output publishes messages to an external system
+type Output interface {
+	Publish(m *Message) error
+	Close() error
+}
+
+// Message defines the json document published by each output
+type Message struct {
+	Type       string      `json:"type"`
+	TS         time.Time   `json:"ts"`
+	TagMAC     string      `json:"tag_mac"`
+	RemoteIP   string      `json:"remote_ip,omitempty"`
+	RemotePort int         `json:"remote_port,omitempty"`
+	Packet     *PacketInfo `json:"packet,omitempty"`
+	Event      interface{} `json:"event,omitempty"`
+}
+
+// PacketInfo defines the json representation of a decoded ccx packet
+type PacketInfo struct {
+	UDPVersion      uint16      `json:"udp_version"`
+	TagMAC          string      `json:"tag_mac"`
+	APMAC           string      `json:"ap_mac"`
+	Sequence        uint16      `json:"sequence"`
+	ProtocolVersion uint8       `json:"protocol_version"`
+	TxPower         uint8       `json:"tx_power"`
+	Channel         uint8       `json:"channel"`
+	RegulatoryClass uint8       `json:"regulatory_class"`
+	BurstLength     uint8       `json:"burst_length"`
+	ProductType     uint16      `json:"product_type"`
+	Battery         BatteryInfo `json:"battery"`
+	TemperatureC    *float32    `json:"temperature_c,omitempty"`
+	UtilState       string      `json:"util_state,omitempty"`
+	Statuses        []string    `json:"statuses"`
+}
+
+// BatteryInfo defines the json representation of the decoded battery group
+type BatteryInfo struct {
+	ChargePercent    int    `json:"charge_percent"`
+	TolerancePercent int    `json:"tolerance_percent"`
+	DaysRemaining    uint16 `json:"days_remaining"`
+	AgeDays          uint32 `json:"age_days"`
+}
+
+// NewPacketMessage creates a new message for the given received and decoded packet
+func NewPacketMessage(du *udp.DataUpdate, packet *ccx.DecodedPacket) *Message {
+	// return the new message
+	return &Message{
+		Type:       PacketMessageType,
+		TS:         du.TS,
+		TagMAC:     packet.TagMAC(),
+		RemoteIP:   du.RemoteIP,
+		RemotePort: du.RemotePort,
+		Packet:     NewPacketInfo(packet),
+	}
+}
+
+// NewPacketInfo creates the json representation of the given decoded packet
+func NewPacketInfo(packet *ccx.DecodedPacket) *PacketInfo {
+	info := &PacketInfo{
+		UDPVersion:      packet.EmanateHeader.UDPVersion,
+		TagMAC:          packet.TagMAC(),
+		APMAC:           packet.APMAC(),
+		Sequence:        packet.EmanateHeader.Sequence,
+		ProtocolVersion: packet.Header.Version,
+		TxPower:         packet.Header.Power,
+		Channel:         packet.Header.Channel,
+		RegulatoryClass: packet.Header.RegulatoryClass,
+		BurstLength:     packet.Header.Burst,
+		ProductType:     packet.System.ProductType,
+		Battery: BatteryInfo{
+			ChargePercent:    packet.BatteryCharge(),
+			TolerancePercent: packet.BatteryTolerance(),
+			DaysRemaining:    packet.Battery.Days,
+			AgeDays:          packet.Battery.Age,
+		},
+		Statuses: packet.Statuses(),
+	}
+
+	// add the optional telemetry values
+	if tempC, ok := packet.Temperature(); ok {
+		info.TemperatureC = &tempC
+	}
+	if state, ok := packet.UtilState(); ok {
+		info.UtilState = state
+	}
+
+	// return the packet info
+	return info
+}
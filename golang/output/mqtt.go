@@ -0,0 +1,248 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// mqtt default option values
+const (
+	DefaultMQTTTopicTemplate = "emanate/{tag}/{kind}"
+	DefaultMQTTClientID      = "emanate_udp_receiver"
+	DefaultMQTTTimeout       = 5 * time.Second
+	DefaultMQTTQueueSize     = 1024
+)
+
+// MQTT publishes messages to an mqtt broker
+type MQTT struct {
+	// the atomic counter is first to keep its 64-bit alignment on 32-bit platforms
+	dropped uint64
+
+	options  *MQTTOptions
+	client   mqtt.Client
+	mutex    sync.RWMutex
+	closed   bool
+	messages chan *Message
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// MQTTOptions provides the instance options
+type MQTTOptions struct {
+	Broker        string
+	ClientID      string
+	Username      string
+	Password      string
+	TopicTemplate string
+	QoS           byte
+	Retain        bool
+	Timeout       time.Duration
+	TLS           *util.TLSFiles
+
+	// QueueSize is the number of messages queued for the publishing goroutine (the messages are
+	// dropped while the queue is full, e.g. while the broker is down)
+	QueueSize int
+}
+
+// NewMQTT creates a new instance and connects to the broker
+func NewMQTT(options *MQTTOptions) (*MQTT, error) {
+	// apply the default option values
+	if options.TopicTemplate == "" {
+		options.TopicTemplate = DefaultMQTTTopicTemplate
+	}
+	if options.ClientID == "" {
+		options.ClientID = DefaultMQTTClientID
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultMQTTTimeout
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultMQTTQueueSize
+	}
+
+	// validate the quality-of-service level (qos 2 is not supported)
+	if options.QoS > 1 {
+		return nil, fmt.Errorf("Unsupported mqtt qos '%d' (must be 0 or 1)", options.QoS)
+	}
+
+	// create the mqtt client options
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetConnectTimeout(options.Timeout).
+		SetAutoReconnect(true)
+
+	// enable tls if configured
+	if options.TLS != nil {
+		config, err := util.LoadTLSConfig(options.TLS)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(config)
+	}
+
+	// connect to the broker
+	client := mqtt.NewClient(clientOptions)
+	token := client.Connect()
+	if !token.WaitTimeout(options.Timeout) {
+		return nil, fmt.Errorf("Timed out connecting to mqtt broker '%s'", options.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+
+	// create the new instance
+	o := &MQTT{
+		options:  options,
+		client:   client,
+		messages: make(chan *Message, options.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	// start the publishing goroutine
+	o.wg.Add(1)
+	go o.run()
+
+	// return the new instance
+	return o, nil
+}
+
+// Publish queues the given message for publishing to its templated topics (so a slow or unreachable
+// broker does not stall the caller)
+func (o *MQTT) Publish(m *Message) error {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	// if already closed
+	if o.closed {
+		return fmt.Errorf("MQTT output is closed (dropping '%s' message)", m.Type)
+	}
+
+	select {
+	case o.messages <- m:
+		return nil
+	default:
+		atomic.AddUint64(&o.dropped, 1)
+		return fmt.Errorf("MQTT publish queue is full (dropping '%s' message)", m.Type)
+	}
+}
+
+// Dropped returns the number of messages dropped while the publish queue was full
+func (o *MQTT) Dropped() uint64 {
+	return atomic.LoadUint64(&o.dropped)
+}
+
+// Close publishes the queued messages (without waiting for further acknowledgements) and disconnects
+// from the broker
+func (o *MQTT) Close() error {
+	// stop accepting messages (once no publish is in progress)
+	o.mutex.Lock()
+	if o.closed {
+		o.mutex.Unlock()
+		return nil
+	}
+	o.closed = true
+	close(o.messages)
+	close(o.done)
+	o.mutex.Unlock()
+
+	// wait for the queued messages, then disconnect
+	o.wg.Wait()
+	o.client.Disconnect(uint(o.options.Timeout / time.Millisecond))
+	return nil
+}
+
+func (o *MQTT) run() {
+	defer o.wg.Done()
+
+	// publish the queued messages until closed
+	for m := range o.messages {
+		if err := o.publish(m); err != nil {
+			fmt.Printf("Error publishing to mqtt broker '%s' (error = '%v')\n", o.options.Broker, err)
+		}
+	}
+}
+
+func (o *MQTT) publish(m *Message) error {
+	// publish each topic payload of the message
+	for kind, payload := range MQTTPayloads(m) {
+		// encode the payload as json
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+
+		// publish the payload (qos 1 waits for the broker acknowledgement, until closed)
+		topic := MQTTTopic(o.options.TopicTemplate, m.TagMAC, kind)
+		token := o.client.Publish(topic, o.options.QoS, o.options.Retain, data)
+		if o.options.QoS > 0 {
+			select {
+			case <-token.Done():
+			case <-time.After(o.options.Timeout):
+				return fmt.Errorf("Timed out publishing to mqtt topic '%s'", topic)
+			case <-o.done:
+				continue
+			}
+			if err := token.Error(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// return successfully
+	return nil
+}
+
+// MQTTTopic returns the topic of the given template with the '{tag}' and '{kind}' fields
+// replaced (the tag mac-address is lowercase without ':' delimiters)
+func MQTTTopic(template string, tagMAC string, kind string) string {
+	tag := strings.ToLower(strings.Replace(tagMAC, ":", "", -1))
+	return strings.NewReplacer("{tag}", tag, "{kind}", kind).Replace(template)
+}
+
+// MQTTPayloads returns the payload of each telemetry kind included in the given message
+func MQTTPayloads(m *Message) map[string]interface{} {
+	// if the message is not a decoded packet, publish the whole message under its type
+	if m.Packet == nil {
+		return map[string]interface{}{m.Type: m}
+	}
+
+	// publish the whole packet plus the last-state of each telemetry kind
+	p := m.Packet
+	payloads := map[string]interface{}{
+		"packet": m,
+		"battery": map[string]interface{}{
+			"ts":      m.TS,
+			"battery": p.Battery,
+		},
+		"ap": map[string]interface{}{
+			"ts":     m.TS,
+			"ap_mac": p.APMAC,
+		},
+	}
+	if p.TemperatureC != nil {
+		payloads["temperature"] = map[string]interface{}{
+			"ts":            m.TS,
+			"temperature_c": *p.TemperatureC,
+		}
+	}
+	if p.UtilState != "" {
+		payloads["util_state"] = map[string]interface{}{
+			"ts":         m.TS,
+			"util_state": p.UtilState,
+		}
+	}
+
+	// return the payloads
+	return payloads
+}
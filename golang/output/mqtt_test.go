@@ -0,0 +1,163 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestMQTTTopic(t *testing.T) {
+	topic := MQTTTopic(DefaultMQTTTopicTemplate, "AA:BB:CC:DD:EE:FF", "temperature")
+	if topic != "emanate/aabbccddeeff/temperature" {
+		t.Errorf("topic = '%s'", topic)
+	}
+}
+
+func TestMQTTPayloads(t *testing.T) {
+	tempC := float32(4.5)
+	m := testMessage(1)
+	m.Packet.TemperatureC = &tempC
+	m.Packet.UtilState = "idle"
+
+	payloads := MQTTPayloads(m)
+	for _, kind := range []string{"packet", "battery", "ap", "temperature", "util_state"} {
+		if _, ok := payloads[kind]; !ok {
+			t.Errorf("missing '%s' payload", kind)
+		}
+	}
+
+	// non-packet messages are published under their message type
+	payloads = MQTTPayloads(&Message{Type: "tag_silent", TagMAC: m.TagMAC})
+	if _, ok := payloads["tag_silent"]; !ok || len(payloads) != 1 {
+		t.Errorf("payloads = %v", payloads)
+	}
+}
+
+// stallHook stalls every published message (delaying its acknowledgement) until released
+type stallHook struct {
+	mochi.HookBase
+	release chan struct{}
+}
+
+func (h *stallHook) ID() string {
+	return "stall"
+}
+
+func (h *stallHook) Provides(b byte) bool {
+	return b == mochi.OnPublish
+}
+
+func (h *stallHook) OnPublish(cl *mochi.Client, pk packets.Packet) (packets.Packet, error) {
+	<-h.release
+	return pk, nil
+}
+
+// startBroker starts an in-process mqtt broker (with the given hooks) on a free loopback port, and
+// returns its address and the function stopping it (also stopped when the test ends)
+func startBroker(t *testing.T, hooks ...mochi.Hook) (string, func()) {
+	broker := mochi.New(&mochi.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	broker.AddHook(new(auth.AllowHook), nil)
+	for _, hook := range hooks {
+		broker.AddHook(hook, nil)
+	}
+	listener := listeners.NewTCP("tcp", "127.0.0.1:0", nil)
+	if err := broker.AddListener(listener); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	once := sync.Once{}
+	stop := func() {
+		once.Do(func() { broker.Close() })
+	}
+	t.Cleanup(stop)
+	return "tcp://" + listener.Address(), stop
+}
+
+func TestMQTTBroker(t *testing.T) {
+	broker, _ := startBroker(t)
+
+	// subscribe to the temperature topic of the test tag
+	received := make(chan mqtt.Message, 1)
+	sub := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID("emanate_test_sub"))
+	if token := sub.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer sub.Disconnect(100)
+	sub.Subscribe("emanate/112233445566/temperature", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		received <- msg
+	}).Wait()
+
+	// publish a decoded packet message
+	o, err := NewMQTT(&MQTTOptions{Broker: broker, ClientID: "emanate_test_pub", QoS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	tempC := float32(4.5)
+	m := testMessage(1)
+	m.Packet.TemperatureC = &tempC
+	if err := o.Publish(m); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		payload := map[string]interface{}{}
+		if err := json.Unmarshal(msg.Payload(), &payload); err != nil || payload["temperature_c"] != 4.5 {
+			t.Errorf("payload = %s", msg.Payload())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the temperature message")
+	}
+}
+
+func TestMQTTSlowBroker(t *testing.T) {
+	stall := &stallHook{release: make(chan struct{})}
+	addr, stopBroker := startBroker(t, stall)
+	defer stopBroker()
+	defer close(stall.release)
+	o, err := NewMQTT(&MQTTOptions{
+		Broker:    addr,
+		ClientID:  "emanate_test_slow",
+		QoS:       1,
+		Timeout:   time.Second,
+		QueueSize: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// publishing to a broker that does not acknowledge does not block, and the messages beyond the
+	// queue are dropped and counted
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		o.Publish(testMessage(i))
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("publish took %v", elapsed)
+	}
+	if dropped := o.Dropped(); dropped < 15 {
+		t.Errorf("dropped = %d, want at least 15", dropped)
+	}
+
+	// close does not wait for the acknowledgements of the queued messages
+	start = time.Now()
+	o.Close()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("close took %v", elapsed)
+	}
+	if err := o.Publish(testMessage(21)); err == nil {
+		t.Error("publish after close succeeded")
+	}
+}
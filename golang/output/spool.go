@@ -0,0 +1,112 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Spool is a disk-backed queue of pending request bodies, ordered by their sequence numbers
+type Spool struct {
+	mutex sync.Mutex
+	dir   string
+	names []string // the sorted queued file paths (the directory is only listed when opened)
+}
+
+// NewSpool creates a new instance storing each queued body as a file in the given directory (the
+// bodies already queued in the directory are kept)
+func NewSpool(dir string) (*Spool, error) {
+	// create the queue directory if needed
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// list the previously queued bodies
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	// return the new instance
+	return &Spool{
+		dir:   dir,
+		names: names,
+	}, nil
+}
+
+// Push adds the given body to the queue at the position of the given sequence number (e.g. its
+// creation time, so a body queued after a failed delivery stays ahead of the newer queued bodies)
+func (s *Spool) Push(seq int64, body []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// name the file so the lexical order is the queue order
+	name := filepath.Join(s.dir, fmt.Sprintf("%020d.json", seq))
+
+	// write to a temporary file first so a partially written body is never sent
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+
+	// insert the file in order (usually at the end of the queue)
+	i := sort.SearchStrings(s.names, name)
+	if i < len(s.names) && s.names[i] == name {
+		return nil
+	}
+	s.names = append(s.names, "")
+	copy(s.names[i+1:], s.names[i:])
+	s.names[i] = name
+	return nil
+}
+
+// Peek returns the name and body of the oldest queued entry (or an empty name if the queue is empty)
+func (s *Spool) Peek() (string, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// if the queue is empty
+	if len(s.names) == 0 {
+		return "", nil, nil
+	}
+
+	// read the oldest queued body
+	body, err := os.ReadFile(s.names[0])
+	return s.names[0], body, err
+}
+
+// Remove removes the given queued entry
+func (s *Spool) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if i := sort.SearchStrings(s.names, name); i < len(s.names) && s.names[i] == name {
+		s.names = append(s.names[:i], s.names[i+1:]...)
+	}
+	return nil
+}
+
+// Len returns the number of queued entries
+func (s *Spool) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.names)
+}
+
+// Before returns whether any queued entry is older than the given sequence number
+func (s *Spool) Before(seq int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.names) > 0 && s.names[0] < filepath.Join(s.dir, fmt.Sprintf("%020d.json", seq))
+}
@@ -0,0 +1,44 @@
+package output
+
+import (
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bodies are queued in their sequence number order, whatever the push order
+	for _, seq := range []int64{30, 10, 20} {
+		if err := s.Push(seq, []byte{byte(seq)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	if !s.Before(11) || s.Before(10) {
+		t.Errorf("Before() does not compare with the oldest sequence number")
+	}
+
+	// the queued bodies are kept when the spool is reopened
+	s, err = NewSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []byte{10, 20, 30} {
+		name, body, err := s.Peek()
+		if err != nil || len(body) != 1 || body[0] != want {
+			t.Fatalf("Peek() = %q, %v, %v, want %d", name, body, err, want)
+		}
+		if err := s.Remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if name, _, _ := s.Peek(); name != "" || s.Len() != 0 {
+		t.Errorf("queue not empty (%q, %d entries)", name, s.Len())
+	}
+}
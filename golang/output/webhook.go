@@ -0,0 +1,358 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhook default option values
+const (
+	DefaultWebhookTimeout       = 5 * time.Second
+	DefaultWebhookBatchSize     = 1
+	DefaultWebhookBatchInterval = 1 * time.Second
+	DefaultWebhookRetryBackoff  = 500 * time.Millisecond
+	webhookChannelSize          = 1024
+)
+
+// Webhook posts messages as json to an http endpoint
+type Webhook struct {
+	options  *WebhookOptions
+	client   *http.Client
+	spool    *Spool
+	mutex    sync.RWMutex
+	closed   bool
+	messages chan *Message
+	batches  chan webhookBatch
+	seq      int64
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// webhookBatch is an encoded batch of messages, with its sequence number giving its delivery order
+type webhookBatch struct {
+	seq  int64
+	body []byte
+}
+
+// webhookStatusError is the error of a post that the endpoint did not accept
+type webhookStatusError struct {
+	status string
+	code   int
+}
+
+// WebhookOptions provides the instance options
+type WebhookOptions struct {
+	URL           string
+	Headers       map[string]string
+	Timeout       time.Duration
+	BatchSize     int
+	BatchInterval time.Duration
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	QueueDir      string
+}
+
+// NewWebhook creates a new instance and starts the background delivery goroutine
+func NewWebhook(options *WebhookOptions) (*Webhook, error) {
+	// apply the default option values
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultWebhookTimeout
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultWebhookBatchSize
+	}
+	if options.BatchInterval <= 0 {
+		options.BatchInterval = DefaultWebhookBatchInterval
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = DefaultWebhookRetryBackoff
+	}
+
+	// create the new instance
+	w := &Webhook{
+		options:  options,
+		client:   &http.Client{Timeout: options.Timeout},
+		messages: make(chan *Message, webhookChannelSize),
+		batches:  make(chan webhookBatch, webhookChannelSize),
+		done:     make(chan struct{}),
+	}
+
+	// create the disk-backed queue if enabled
+	if options.QueueDir != "" {
+		spool, err := NewSpool(options.QueueDir)
+		if err != nil {
+			return nil, err
+		}
+		w.spool = spool
+	}
+
+	// start the batching and delivery goroutines
+	w.wg.Add(2)
+	go w.run()
+	go w.deliver()
+
+	// return the new instance
+	return w, nil
+}
+
+// Publish queues the given message for delivery to the webhook endpoint
+func (w *Webhook) Publish(m *Message) error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	// if already closed
+	if w.closed {
+		return fmt.Errorf("Webhook is closed (dropping '%s' message)", m.Type)
+	}
+
+	select {
+	case w.messages <- m:
+		return nil
+	default:
+		return fmt.Errorf("Webhook delivery queue is full (dropping '%s' message)", m.Type)
+	}
+}
+
+// Close delivers any pending messages (without further retry delays) and stops the delivery goroutines
+func (w *Webhook) Close() error {
+	// stop accepting messages (once no publish is in progress)
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.messages)
+	close(w.done)
+	w.mutex.Unlock()
+
+	// wait for the pending batches to be delivered
+	w.wg.Wait()
+	return nil
+}
+
+func (w *Webhook) run() {
+	defer w.wg.Done()
+
+	// create the batch interval ticker
+	ticker := time.NewTicker(w.options.BatchInterval)
+	defer ticker.Stop()
+
+	// collect messages into batches until closed
+	batch := []*Message{}
+	for {
+		select {
+		case m, ok := <-w.messages:
+			// if closed, deliver the final batch and stop the delivery goroutine
+			if !ok {
+				w.flush(batch)
+				close(w.batches)
+				return
+			}
+
+			// deliver the batch when full
+			batch = append(batch, m)
+			if len(batch) >= w.options.BatchSize {
+				w.flush(batch)
+				batch = []*Message{}
+			}
+
+		case <-ticker.C:
+			// deliver the partial batch
+			w.flush(batch)
+			batch = []*Message{}
+		}
+	}
+}
+
+func (w *Webhook) deliver() {
+	defer w.wg.Done()
+
+	// create the queue retry ticker
+	ticker := time.NewTicker(w.options.BatchInterval)
+	defer ticker.Stop()
+
+	// post the batches (off the batching goroutine, so a failing endpoint does not stall it)
+	for {
+		select {
+		case b, ok := <-w.batches:
+			// if closed, return now
+			if !ok {
+				return
+			}
+			w.send(b)
+
+		case <-ticker.C:
+			// retry any queued batches (once the older batches handed over are posted)
+			if len(w.batches) == 0 {
+				w.drain(math.MaxInt64)
+			}
+		}
+	}
+}
+
+func (w *Webhook) flush(batch []*Message) {
+	// if there is nothing to deliver
+	if len(batch) == 0 {
+		return
+	}
+
+	// encode a single message as an object, otherwise as an array
+	var body []byte
+	var err error
+	if w.options.BatchSize <= 1 && len(batch) == 1 {
+		body, err = json.Marshal(batch[0])
+	} else {
+		body, err = json.Marshal(batch)
+	}
+	if err != nil {
+		fmt.Printf("Error encoding webhook messages (error = '%v')\n", err)
+		return
+	}
+
+	// number the batch in its creation order (increasing even if the clock steps back, the numbers
+	// also order the batches queued to disk by a previous run)
+	seq := time.Now().UnixNano()
+	if seq <= w.seq {
+		seq = w.seq + 1
+	}
+	w.seq = seq
+
+	// hand the batch to the delivery goroutine (queueing it now if the delivery is backed up)
+	b := webhookBatch{seq: seq, body: body}
+	select {
+	case w.batches <- b:
+	default:
+		w.enqueue(b)
+	}
+}
+
+func (w *Webhook) send(b webhookBatch) {
+	// if older batches are queued (e.g. after a failed post), queue this batch behind them to preserve
+	// the order (the batches queued while the delivery was backed up are newer, and are posted later)
+	if w.spool != nil && w.spool.Before(b.seq) {
+		w.enqueue(b)
+		w.drain(b.seq + 1)
+		return
+	}
+
+	// post the batch (retrying with backoff)
+	if err := w.postWithRetry(b.body); err != nil {
+		fmt.Printf("Error posting to webhook '%s' (error = '%v')\n", w.options.URL, err)
+
+		// the batches rejected by the endpoint would fail again, so they are dropped
+		if rejected(err) {
+			fmt.Printf("Dropped webhook batch rejected by the endpoint (%d bytes)\n", len(b.body))
+			return
+		}
+		w.enqueue(b)
+	}
+}
+
+func (w *Webhook) enqueue(b webhookBatch) {
+	// if the disk-backed queue is not enabled, the batch is dropped
+	if w.spool == nil {
+		fmt.Printf("Dropped webhook batch (%d bytes)\n", len(b.body))
+		return
+	}
+
+	// queue the batch to disk
+	if err := w.spool.Push(b.seq, b.body); err != nil {
+		fmt.Printf("Error queueing webhook batch to '%s' (error = '%v')\n", w.options.QueueDir, err)
+	}
+}
+
+func (w *Webhook) drain(until int64) {
+	// if the disk-backed queue is not enabled
+	if w.spool == nil {
+		return
+	}
+
+	// post the queued batches older than the given sequence number in order, until a post fails
+	// (dropping the batches rejected by the endpoint so they do not block the queue)
+	for w.spool.Before(until) {
+		name, body, err := w.spool.Peek()
+		if err != nil || name == "" {
+			return
+		}
+		if err := w.post(body); err != nil {
+			if !rejected(err) {
+				return
+			}
+			fmt.Printf("Dropped queued webhook batch '%s' rejected by the endpoint (error = '%v')\n", name, err)
+		}
+		if err := w.spool.Remove(name); err != nil {
+			fmt.Printf("Error removing queued webhook batch '%s' (error = '%v')\n", name, err)
+			return
+		}
+	}
+}
+
+func (w *Webhook) postWithRetry(body []byte) error {
+	// post the body, doubling the backoff delay after each failed attempt (until closed)
+	backoff := w.options.RetryBackoff
+	err := w.post(body)
+	for attempt := 0; err != nil && !rejected(err) && attempt < w.options.MaxRetries; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-w.done:
+			return err
+		}
+		backoff = backoff * 2
+		err = w.post(body)
+	}
+
+	// return whether an error occurred
+	return err
+}
+
+func (w *Webhook) post(body []byte) error {
+	// create the http request
+	req, err := http.NewRequest(http.MethodPost, w.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.options.Headers {
+		req.Header.Set(k, v)
+	}
+
+	// send the request
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	// if the endpoint did not accept the request
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{status: resp.Status, code: resp.StatusCode}
+	}
+
+	// return successfully
+	return nil
+}
+
+// Error returns the error message
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("Unexpected webhook response status '%s'", e.status)
+}
+
+// rejected returns whether the given post error is a permanent rejection of the request by the
+// endpoint (a 4xx status other than 408 request timeout and 429 too many requests)
+func rejected(err error) bool {
+	statusErr := &webhookStatusError{}
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.code >= 400 && statusErr.code <= 499 &&
+		statusErr.code != http.StatusRequestTimeout && statusErr.code != http.StatusTooManyRequests
+}
@@ -0,0 +1,280 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records the requests received by a test webhook endpoint
+type webhookServer struct {
+	*httptest.Server
+	mutex  sync.Mutex
+	down   bool
+	bodies [][]byte
+	header http.Header
+}
+
+func newWebhookServer() *webhookServer {
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.bodies = append(s.bodies, body)
+		s.header = r.Header
+	}))
+	return s
+}
+
+func (s *webhookServer) setDown(down bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.down = down
+}
+
+func (s *webhookServer) received() [][]byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([][]byte{}, s.bodies...)
+}
+
+func testMessage(seq int) *Message {
+	return &Message{
+		Type:   PacketMessageType,
+		TS:     time.Unix(1700000000, 0),
+		TagMAC: "11:22:33:44:55:66",
+		Packet: &PacketInfo{Sequence: uint16(seq)},
+	}
+}
+
+func TestWebhookPostsMessagesWithHeaders(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+
+	w, err := NewWebhook(&WebhookOptions{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Publish(testMessage(1))
+	w.Close()
+
+	bodies := server.received()
+	if len(bodies) != 1 {
+		t.Fatalf("requests = %d, want 1", len(bodies))
+	}
+	m := Message{}
+	if err := json.Unmarshal(bodies[0], &m); err != nil {
+		t.Fatalf("invalid json body %s: %v", bodies[0], err)
+	}
+	if m.Packet.Sequence != 1 || m.TagMAC != "11:22:33:44:55:66" {
+		t.Errorf("message = %+v", m)
+	}
+	if server.header.Get("Authorization") != "Bearer secret" || server.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", server.header)
+	}
+}
+
+func TestWebhookBatches(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+
+	w, _ := NewWebhook(&WebhookOptions{
+		URL:           server.URL,
+		BatchSize:     3,
+		BatchInterval: time.Hour,
+	})
+	for i := 1; i <= 4; i++ {
+		w.Publish(testMessage(i))
+	}
+	w.Close()
+
+	bodies := server.received()
+	if len(bodies) != 2 {
+		t.Fatalf("requests = %d, want 2", len(bodies))
+	}
+	batch := []Message{}
+	if err := json.Unmarshal(bodies[0], &batch); err != nil || len(batch) != 3 {
+		t.Errorf("first batch = %s (error = %v)", bodies[0], err)
+	}
+}
+
+func TestWebhookQueuesWhileEndpointIsDown(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+	server.setDown(true)
+
+	w, _ := NewWebhook(&WebhookOptions{
+		URL:           server.URL,
+		BatchInterval: 10 * time.Millisecond,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+		QueueDir:      t.TempDir(),
+	})
+	w.Publish(testMessage(1))
+	w.Publish(testMessage(2))
+
+	// wait until both messages are queued to disk
+	deadline := time.Now().Add(5 * time.Second)
+	for w.spool.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := w.spool.Len(); n != 2 {
+		t.Fatalf("queued batches = %d, want 2", n)
+	}
+
+	// bring the endpoint back and wait for the queue to drain in order
+	server.setDown(false)
+	for w.spool.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Close()
+
+	bodies := server.received()
+	if len(bodies) != 2 {
+		t.Fatalf("requests = %d, want 2", len(bodies))
+	}
+	for i, body := range bodies {
+		m := Message{}
+		if err := json.Unmarshal(body, &m); err != nil || int(m.Packet.Sequence) != i+1 {
+			t.Errorf("request #%d = %s (error = %v)", i, body, err)
+		}
+	}
+}
+
+func TestWebhookFailingEndpointDoesNotStallPublish(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+	server.setDown(true)
+
+	// every failed post is retried for more than a second
+	w, _ := NewWebhook(&WebhookOptions{
+		URL:          server.URL,
+		MaxRetries:   4,
+		RetryBackoff: 100 * time.Millisecond,
+	})
+
+	// more messages than the delivery queue are still accepted while the retries are pending
+	for i := 0; i < 2*webhookChannelSize; i++ {
+		if err := w.Publish(testMessage(i)); err != nil {
+			t.Fatalf("message #%d: %v", i, err)
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+
+	// closing aborts the retry delays
+	start := time.Now()
+	w.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Close() took %v", elapsed)
+	}
+}
+
+func TestWebhookPublishAfterClose(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+
+	// publishing concurrently with close never panics
+	w, _ := NewWebhook(&WebhookOptions{URL: server.URL})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w.Publish(testMessage(j))
+			}
+		}()
+	}
+	w.Close()
+	wg.Wait()
+	if err := w.Publish(testMessage(1)); err == nil {
+		t.Error("Publish() after Close() succeeded")
+	}
+}
+
+func TestWebhookSendOrder(t *testing.T) {
+	server := newWebhookServer()
+	defer server.Close()
+	w, _ := NewWebhook(&WebhookOptions{
+		URL:           server.URL,
+		BatchInterval: time.Hour,
+		QueueDir:      t.TempDir(),
+	})
+	defer w.Close()
+
+	// a batch older than the queued batches (e.g. handed over before the delivery backed up) is posted
+	// first, and a batch newer than a queued batch (e.g. after a failed post) is posted behind it
+	w.spool.Push(30, []byte(`"30"`))
+	w.send(webhookBatch{seq: 20, body: []byte(`"20"`)})
+	w.spool.Push(10, []byte(`"10"`))
+	w.send(webhookBatch{seq: 25, body: []byte(`"25"`)})
+	w.drain(math.MaxInt64)
+
+	bodies := server.received()
+	want := []string{`"20"`, `"10"`, `"25"`, `"30"`}
+	if len(bodies) != len(want) {
+		t.Fatalf("requests = %q, want %q", bodies, want)
+	}
+	for i := range want {
+		if string(bodies[i]) != want[i] {
+			t.Errorf("request #%d = %s, want %s", i, bodies[i], want[i])
+		}
+	}
+}
+
+func TestWebhookDropsRejectedBatches(t *testing.T) {
+	// the endpoint rejects the bad request bodies
+	var mutex sync.Mutex
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, string(body))
+		mutex.Unlock()
+		if string(body) == `"bad"` {
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	w, _ := NewWebhook(&WebhookOptions{
+		URL:           server.URL,
+		BatchInterval: time.Hour,
+		MaxRetries:    3,
+		RetryBackoff:  time.Millisecond,
+		QueueDir:      t.TempDir(),
+	})
+	defer w.Close()
+
+	// a rejected batch is not retried, and is not queued
+	w.send(webhookBatch{seq: 1, body: []byte(`"bad"`)})
+	if n := w.spool.Len(); n != 0 {
+		t.Errorf("queued batches = %d, want 0", n)
+	}
+
+	// a rejected queued batch is dropped instead of blocking the following queued batches
+	w.spool.Push(2, []byte(`"bad"`))
+	w.spool.Push(3, []byte(`"good"`))
+	w.drain(math.MaxInt64)
+	if n := w.spool.Len(); n != 0 {
+		t.Errorf("queued batches = %d, want 0", n)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if want := []string{`"bad"`, `"bad"`, `"good"`}; strings.Join(requests, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,301 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/auth"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// DefaultQueueSize is the default number of datagrams queued for each destination
+const DefaultQueueSize = 1024
+
+// Proxy relays every received datagram to multiple destinations
+type Proxy struct {
+	// the atomic counters are first to keep their 64-bit alignment on 32-bit platforms
+	received        uint64
+	filtered        uint64
+	unauthenticated uint64
+
+	options      *ProxyOptions
+	verifier     *auth.Verifier
+	destinations []*destination
+	tagMACs      map[string]bool
+	productTypes map[uint16]bool
+	rewriteAPMAC *[6]byte
+	wg           sync.WaitGroup
+	closeOnce    sync.Once
+}
+
+// ProxyOptions provides the instance options
+type ProxyOptions struct {
+	// Destinations are the 'host:port' addresses the datagrams are relayed to
+	Destinations []string
+
+	// Transport is the udp (default), tcp, or tls transport of the relayed packets, and TLS are the
+	// certificate files of the tls transport
+	Transport string
+	TLS       *util.TLSFiles
+
+	// TagMACs relays only the packets of the given tags (every tag if empty)
+	TagMACs []string
+
+	// ProductTypes relays only the packets of the given product types (every product type if empty)
+	ProductTypes []uint16
+
+	// RewriteAPMAC replaces the access point mac-address of every relayed packet (unchanged if empty)
+	RewriteAPMAC string
+
+	// Keyring verifies the authentication trailer of every relayed packet, dropping the
+	// unauthenticated, forged, and replayed packets (every packet is relayed unverified if nil)
+	Keyring *auth.Keyring
+
+	// AuthKey and AuthKeyID re-sign the verified packets with the proxy key (e.g. for receivers only
+	// accepting the proxy key, the packets keep their original trailer if no key is given), which
+	// requires the Keyring so that only authentic packets are ever signed
+	AuthKey   []byte
+	AuthKeyID uint8
+
+	// QueueSize is the number of datagrams queued for each destination (the datagrams are dropped
+	// while the queue of a slow or unreachable destination is full)
+	QueueSize int
+}
+
+// Stats defines the proxy packet counters
+type Stats struct {
+	Received        uint64              `json:"received"`
+	Filtered        uint64              `json:"filtered"`
+	Unauthenticated uint64              `json:"unauthenticated"`
+	Destinations    []*DestinationStats `json:"destinations"`
+}
+
+// DestinationStats defines the packet counters of a single destination
+type DestinationStats struct {
+	Destination string `json:"destination"`
+	Packets     uint64 `json:"packets"`
+	Bytes       uint64 `json:"bytes"`
+	Errors      uint64 `json:"errors"`
+	Dropped     uint64 `json:"dropped"`
+}
+
+// destination relays the queued datagrams to a single address on its own goroutine, so a slow or
+// unreachable destination does not delay the others
+type destination struct {
+	sender udp.PacketSender
+	queue  chan []byte
+	mutex  sync.Mutex
+	stats  DestinationStats
+}
+
+// NewProxy creates a new instance
+func NewProxy(options *ProxyOptions) (*Proxy, error) {
+	// apply the default option values
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+
+	p := &Proxy{
+		options:      options,
+		tagMACs:      map[string]bool{},
+		productTypes: map[uint16]bool{},
+	}
+
+	// create the filters
+	for _, tag := range options.TagMACs {
+		mac, err := util.ParseMAC(tag)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy tag mac-address filter '%s' (%v)", tag, err)
+		}
+		p.tagMACs[mac] = true
+	}
+	for _, pt := range options.ProductTypes {
+		p.productTypes[pt] = true
+	}
+
+	// create the packet authentication verifier if enabled (only the verified packets are re-signed)
+	if options.Keyring != nil {
+		p.verifier = auth.NewVerifier(&auth.VerifierOptions{Keyring: options.Keyring})
+	} else if options.AuthKey != nil {
+		return nil, fmt.Errorf("Re-signing the proxy packets needs the authentication keys to verify them")
+	}
+
+	// create the destination senders
+	if len(options.Destinations) == 0 {
+		return nil, fmt.Errorf("At least one proxy destination is needed")
+	}
+	for _, dst := range options.Destinations {
+		host, portStr, err := net.SplitHostPort(dst)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy destination '%s' (expected 'host:port')", dst)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy destination port '%s'", dst)
+		}
+		sender := udp.NewSender(&udp.SenderOptions{
+			Host:      host,
+			Port:      port,
+			Quiet:     true,
+			Transport: options.Transport,
+			TLS:       options.TLS,
+		})
+		p.destinations = append(p.destinations, &destination{
+			sender: sender,
+			queue:  make(chan []byte, options.QueueSize),
+			stats:  DestinationStats{Destination: sender.Destination()},
+		})
+	}
+
+	// parse the rewritten access point mac-address
+	if options.RewriteAPMAC != "" {
+		ap, err := util.MACAddrToBytes(util.NormalizeMAC(options.RewriteAPMAC))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy rewrite ap mac-address '%s' (%v)", options.RewriteAPMAC, err)
+		}
+		p.rewriteAPMAC = &ap
+	}
+
+	// start relaying to every destination
+	for _, d := range p.destinations {
+		p.wg.Add(1)
+		go p.relay(d)
+	}
+
+	// return the new instance
+	return p, nil
+}
+
+// Handle relays the given received datagram to every destination (used as the udp receiver data handler)
+func (p *Proxy) Handle(du *udp.DataUpdate) {
+	atomic.AddUint64(&p.received, 1)
+
+	// filter the packet
+	data := du.Data
+	if !p.accepts(data) {
+		atomic.AddUint64(&p.filtered, 1)
+		return
+	}
+
+	// verify the authentication trailer if enabled, and re-sign the verified packet with the proxy key
+	// if given
+	if p.verifier != nil {
+		verified, err := p.verifier.Verify(data, du.TS)
+		if err != nil {
+			atomic.AddUint64(&p.unauthenticated, 1)
+			return
+		}
+		if p.options.AuthKey != nil {
+			data = auth.Sign(verified, p.options.AuthKeyID, p.options.AuthKey)
+		}
+	}
+
+	// rewrite the packet fields (on a copy of the received datagram, the access point mac-address is
+	// not covered by the authentication trailer)
+	if p.rewriteAPMAC != nil && len(data) >= ccx.APMACOffset+len(p.rewriteAPMAC) {
+		data = append([]byte{}, data...)
+		copy(data[ccx.APMACOffset:], p.rewriteAPMAC[:])
+	}
+
+	// queue the packet for every destination (dropping it for the destinations that are behind)
+	for _, d := range p.destinations {
+		select {
+		case d.queue <- data:
+		default:
+			d.mutex.Lock()
+			d.stats.Dropped++
+			d.mutex.Unlock()
+		}
+	}
+}
+
+// Stats returns a snapshot of the proxy packet counters
+func (p *Proxy) Stats() *Stats {
+	stats := &Stats{
+		Received:        atomic.LoadUint64(&p.received),
+		Filtered:        atomic.LoadUint64(&p.filtered),
+		Unauthenticated: atomic.LoadUint64(&p.unauthenticated),
+	}
+	for _, d := range p.destinations {
+		d.mutex.Lock()
+		ds := d.stats
+		d.mutex.Unlock()
+		stats.Destinations = append(stats.Destinations, &ds)
+	}
+	return stats
+}
+
+// Dump prints the proxy packet counters to the console
+func (p *Proxy) Dump() {
+	stats := p.Stats()
+	fmt.Printf("\nUDP PROXY STATS\n")
+	fmt.Printf("===============\n\n")
+	fmt.Printf("  - Received = %d\n", stats.Received)
+	fmt.Printf("  - Filtered = %d\n", stats.Filtered)
+	fmt.Printf("  - Unauthenticated = %d\n", stats.Unauthenticated)
+	for _, ds := range stats.Destinations {
+		fmt.Printf("  - Destination '%s' = %d packets, %d bytes, %d errors, %d dropped\n",
+			ds.Destination, ds.Packets, ds.Bytes, ds.Errors, ds.Dropped)
+	}
+	fmt.Printf("\n")
+}
+
+// Close relays the queued datagrams and closes every destination socket (Handle must not be called
+// after Close)
+func (p *Proxy) Close() error {
+	p.closeOnce.Do(func() {
+		for _, d := range p.destinations {
+			close(d.queue)
+		}
+		p.wg.Wait()
+		for _, d := range p.destinations {
+			d.sender.Close()
+		}
+	})
+	return nil
+}
+
+func (p *Proxy) relay(d *destination) {
+	defer p.wg.Done()
+
+	// send the queued datagrams until closed
+	for data := range d.queue {
+		err := d.sender.Transmit(data)
+
+		// update the destination counters
+		d.mutex.Lock()
+		if err != nil {
+			d.stats.Errors++
+		} else {
+			d.stats.Packets++
+			d.stats.Bytes += uint64(len(data))
+		}
+		d.mutex.Unlock()
+	}
+}
+
+// accepts returns whether the given datagram passes the tag and product type filters
+func (p *Proxy) accepts(data []byte) bool {
+	// every datagram is relayed if no filters are given
+	if len(p.tagMACs) == 0 && len(p.productTypes) == 0 {
+		return true
+	}
+
+	// the filtered fields are in the static packet fields
+	packet, _ := ccx.Decode(data)
+	if packet == nil {
+		return false
+	}
+	if len(p.tagMACs) > 0 && !p.tagMACs[packet.TagMAC()] {
+		return false
+	}
+	if len(p.productTypes) > 0 && !p.productTypes[packet.System.ProductType] {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/auth"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+func listen(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func tagPacket(t *testing.T, tag string) []byte {
+	p := ccx.NewPacket()
+	if err := p.SetTagMACAddress(tag); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestProxy(t *testing.T) {
+	// create two destinations
+	dst1 := listen(t)
+	dst2 := listen(t)
+	p, err := NewProxy(&ProxyOptions{
+		Destinations: []string{dst1.LocalAddr().String(), dst2.LocalAddr().String()},
+		TagMACs:      []string{"112233445566"},
+		RewriteAPMAC: "AA-BB-CC-DD-EE-FF",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// relay a matching packet, a filtered tag, and a non-ccx datagram
+	p.Handle(&udp.DataUpdate{Data: tagPacket(t, "11:22:33:44:55:66")})
+	p.Handle(&udp.DataUpdate{Data: tagPacket(t, "66:55:44:33:22:11")})
+	p.Handle(&udp.DataUpdate{Data: []byte("hello")})
+
+	// both destinations receive only the matching packet with the rewritten access point
+	for _, dst := range []*net.UDPConn{dst1, dst2} {
+		buf := make([]byte, 2048)
+		dst.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := dst.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet, err := ccx.Decode(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packet.TagMAC() != "11:22:33:44:55:66" || packet.APMAC() != "AA:BB:CC:DD:EE:FF" {
+			t.Errorf("relayed packet tag = '%s', ap = '%s'", packet.TagMAC(), packet.APMAC())
+		}
+	}
+
+	// check the counters (once every queued datagram is relayed)
+	p.Close()
+	stats := p.Stats()
+	if stats.Received != 3 || stats.Filtered != 2 || stats.Destinations[0].Packets != 1 || stats.Destinations[1].Packets != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestInvalidTagFilter(t *testing.T) {
+	// an invalid tag filter would never match, so it is rejected
+	for _, tag := range []string{"11:22:33", "11:22:33:44:55:ZZ", "tag-1"} {
+		if _, err := NewProxy(&ProxyOptions{Destinations: []string{"127.0.0.1:9999"}, TagMACs: []string{tag}}); err == nil {
+			t.Errorf("tag filter '%s' accepted", tag)
+		}
+	}
+}
+
+func TestAuthenticatedPacket(t *testing.T) {
+	siteKey, _ := auth.ParseKey("000102030405060708090a0b0c0d0e0f")
+	proxyKey, _ := auth.ParseKey("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	keyring, _ := auth.NewKeyring(&auth.KeyringConfig{Keys: []auth.KeyConfig{{ID: 3, Key: "000102030405060708090a0b0c0d0e0f"}}})
+	proxyKeyring, _ := auth.NewKeyring(&auth.KeyringConfig{Keys: []auth.KeyConfig{{ID: 7, Key: "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"}}})
+	unsigned := tagPacket(t, "11:22:33:44:55:66")
+	signed := auth.Sign(unsigned, 3, siteKey)
+	forged := auth.Sign(unsigned, 3, proxyKey)
+
+	for _, tt := range []struct {
+		authKey []byte
+		keyring *auth.Keyring
+	}{
+		{nil, keyring},
+		{proxyKey, proxyKeyring},
+	} {
+		dst := listen(t)
+		p, err := NewProxy(&ProxyOptions{
+			Destinations: []string{dst.LocalAddr().String()},
+			RewriteAPMAC: "AA:BB:CC:DD:EE:FF",
+			Keyring:      keyring,
+			AuthKey:      tt.authKey,
+			AuthKeyID:    7,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the forged and unsigned packets are dropped instead of being relayed (or signed)
+		p.Handle(&udp.DataUpdate{Data: forged})
+		p.Handle(&udp.DataUpdate{Data: unsigned})
+		p.Handle(&udp.DataUpdate{Data: signed})
+		p.Close()
+		if stats := p.Stats(); stats.Unauthenticated != 2 || stats.Destinations[0].Packets != 1 {
+			t.Errorf("stats = %+v, %+v", stats, stats.Destinations[0])
+		}
+
+		// the verified packet keeps a valid trailer after the ap mac-address rewrite (re-signed with the
+		// proxy key if given)
+		buf := make([]byte, 2048)
+		dst.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := dst.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := auth.NewVerifier(&auth.VerifierOptions{Keyring: tt.keyring})
+		data, err := v.Verify(buf[:n], time.Now())
+		if err != nil {
+			t.Fatalf("relayed packet error = %v", err)
+		}
+		if decoded, _ := ccx.Decode(data); decoded == nil || decoded.APMAC() != "AA:BB:CC:DD:EE:FF" {
+			t.Errorf("relayed packet = %x", data)
+		}
+	}
+
+	// re-signing without verifying is rejected
+	if _, err := NewProxy(&ProxyOptions{Destinations: []string{"127.0.0.1:9999"}, AuthKey: proxyKey}); err == nil {
+		t.Error("NewProxy() re-signing without keys succeeded")
+	}
+}
+
+// blockingSender blocks every transmit until released (e.g. an unreachable tcp destination)
+type blockingSender struct {
+	release chan struct{}
+	sent    uint64
+}
+
+func (s *blockingSender) Transmit(data []byte) error {
+	<-s.release
+	atomic.AddUint64(&s.sent, 1)
+	return nil
+}
+
+func (s *blockingSender) Close() error {
+	return nil
+}
+
+func TestSlowDestination(t *testing.T) {
+	dst := listen(t)
+	p, err := NewProxy(&ProxyOptions{
+		Destinations: []string{"127.0.0.1:9", dst.LocalAddr().String()},
+		QueueSize:    4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first destination is stuck (before any datagram is queued for it)
+	slow := &blockingSender{release: make(chan struct{})}
+	p.destinations[0].sender.Close()
+	p.destinations[0].sender = slow
+
+	// the other destination still receives every datagram while the slow queue overflows
+	for i := 0; i < 10; i++ {
+		p.Handle(&udp.DataUpdate{Data: []byte("packet")})
+		buf := make([]byte, 2048)
+		dst.SetReadDeadline(time.Now().Add(time.Second))
+		if _, _, err := dst.ReadFromUDP(buf); err != nil {
+			t.Fatalf("datagram #%d: %v", i, err)
+		}
+	}
+
+	// the slow destination relays its queued datagrams once released
+	close(slow.release)
+	p.Close()
+	stats := p.Stats()
+	if sent := atomic.LoadUint64(&slow.sent); sent+stats.Destinations[0].Dropped != 10 || sent < 4 || sent > 5 {
+		t.Errorf("slow destination sent %d, stats = %+v", sent, stats.Destinations[0])
+	}
+	if stats.Destinations[1].Packets != 10 || stats.Destinations[1].Dropped != 0 {
+		t.Errorf("stats = %+v", stats.Destinations[1])
+	}
+}
@@ -0,0 +1,34 @@
+package state
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// Handler returns the http handler serving the 'GET /tags' and 'GET /tags/{mac}' json endpoints
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the GET method is supported
+		if r.Method != http.MethodGet {
+			util.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		// if no tag mac-address is given, return every tag state
+		mac := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags"), "/")
+		if mac == "" {
+			util.WriteJSON(w, http.StatusOK, s.All())
+			return
+		}
+
+		// return the state of the given tag
+		state, ok := s.Get(mac)
+		if !ok {
+			util.WriteJSON(w, http.StatusNotFound, map[string]string{"error": "tag '" + mac + "' not found"})
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, state)
+	})
+}
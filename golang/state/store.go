@@ -0,0 +1,112 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/output"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// Store holds the last-known state of every tag
+type Store struct {
+	mutex sync.RWMutex
+	tags  map[string]*TagState
+}
+
+// TagState defines the last-known state of a single tag
+type TagState struct {
+	TagMAC        string             `json:"tag_mac"`
+	APMAC         string             `json:"ap_mac"`
+	RemoteIP      string             `json:"remote_ip"`
+	FirstSeen     time.Time          `json:"first_seen"`
+	LastSeen      time.Time          `json:"last_seen"`
+	Packets       int                `json:"packets"`
+	Battery       output.BatteryInfo `json:"battery"`
+	TemperatureC  *float32           `json:"temperature_c,omitempty"`
+	TemperatureTS *time.Time         `json:"temperature_ts,omitempty"`
+	UtilState     string             `json:"util_state,omitempty"`
+	Statuses      []string           `json:"statuses"`
+	LastPacket    *output.PacketInfo `json:"last_packet"`
+}
+
+// NewStore creates a new empty instance
+func NewStore() *Store {
+	// return the new instance
+	return &Store{
+		tags: map[string]*TagState{},
+	}
+}
+
+// Update updates the state of the tag that sent the given received and decoded packet
+func (s *Store) Update(du *udp.DataUpdate, packet *ccx.DecodedPacket) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// get the tag state (or create it if this is the first packet from the tag)
+	tag := packet.TagMAC()
+	state, ok := s.tags[tag]
+	if !ok {
+		state = &TagState{
+			TagMAC:    tag,
+			FirstSeen: du.TS,
+		}
+		s.tags[tag] = state
+	}
+
+	// update the latest packet values
+	info := output.NewPacketInfo(packet)
+	state.APMAC = info.APMAC
+	state.RemoteIP = du.RemoteIP
+	state.LastSeen = du.TS
+	state.Packets++
+	state.Battery = info.Battery
+	state.Statuses = info.Statuses
+	state.LastPacket = info
+
+	// keep the last-known optional telemetry values when they are not included in the packet
+	if info.TemperatureC != nil {
+		ts := du.TS
+		state.TemperatureC = info.TemperatureC
+		state.TemperatureTS = &ts
+	}
+	if info.UtilState != "" {
+		state.UtilState = info.UtilState
+	}
+}
+
+// Get returns a copy of the state of the given tag mac-address
+func (s *Store) Get(mac string) (TagState, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// find the tag state
+	state, ok := s.tags[util.NormalizeMAC(mac)]
+	if !ok {
+		return TagState{}, false
+	}
+
+	// return a copy of the tag state
+	return *state, true
+}
+
+// All returns a copy of the state of every tag, sorted by tag mac-address
+func (s *Store) All() []TagState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// copy every tag state
+	states := []TagState{}
+	for _, state := range s.tags {
+		states = append(states, *state)
+	}
+
+	// sort the tag states by mac-address
+	sort.Slice(states, func(i, j int) bool { return states[i].TagMAC < states[j].TagMAC })
+
+	// return the tag states
+	return states
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/udp"
+)
+
+// update decodes the packet of the given builder and updates the store with it
+func update(t *testing.T, s *Store, b *ccx.Builder, ts time.Time) {
+	p, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := p.Pack()
+	packet, err := ccx.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Update(&udp.DataUpdate{TS: ts, RemoteIP: "10.0.0.7", Data: data}, packet)
+}
+
+// newTestStore returns a store updated with the packets of two tags
+func newTestStore(t *testing.T, ts time.Time) *Store {
+	s := NewStore()
+	update(t, s, ccx.NewBuilder().WithTagMAC("AA:BB:CC:DD:EE:FF").WithSequence(1), ts)
+	update(t, s, ccx.NewBuilder().WithTagMAC("11:22:33:44:55:66").WithSequence(1).
+		WithTemperature(21.5).WithUtilState("idle"), ts)
+	update(t, s, ccx.NewBuilder().WithTagMAC("11:22:33:44:55:66").WithSequence(2).
+		WithStatus(ccx.ButtonPressedTelemetry), ts.Add(time.Minute))
+	return s
+}
+
+func TestStore(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	s := newTestStore(t, ts)
+
+	// the tag is found by any mac-address format, and keeps its last-known optional telemetry values
+	state, ok := s.Get("11-22-33-44-55-66")
+	if !ok {
+		t.Fatal("tag not found")
+	}
+	if state.Packets != 2 || !state.FirstSeen.Equal(ts) || !state.LastSeen.Equal(ts.Add(time.Minute)) {
+		t.Errorf("state = %+v", state)
+	}
+	if state.TemperatureC == nil || *state.TemperatureC != 21.5 || !state.TemperatureTS.Equal(ts) {
+		t.Errorf("temperature = %v at %v", state.TemperatureC, state.TemperatureTS)
+	}
+	if state.UtilState != "idle" || len(state.Statuses) != 1 || state.Statuses[0] != ccx.ButtonPressedTelemetry {
+		t.Errorf("util-state = %q, statuses = %v", state.UtilState, state.Statuses)
+	}
+
+	// every tag is returned sorted by mac-address
+	all := s.All()
+	if len(all) != 2 || all[0].TagMAC != "11:22:33:44:55:66" || all[1].TagMAC != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("all = %+v", all)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := newTestStore(t, time.Unix(1700000000, 0)).Handler()
+	get := func(method, path string, v interface{}) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if v != nil {
+			if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+				t.Errorf("%s %s body: %v", method, path, err)
+			}
+		}
+		return rec.Code
+	}
+
+	// every tag state
+	all := []TagState{}
+	if status := get(http.MethodGet, "/tags", &all); status != http.StatusOK || len(all) != 2 {
+		t.Errorf("GET /tags = %d, %+v", status, all)
+	}
+
+	// a single tag state
+	state := TagState{}
+	if status := get(http.MethodGet, "/tags/aabbccddeeff", &state); status != http.StatusOK || state.TagMAC != "AA:BB:CC:DD:EE:FF" {
+		t.Errorf("GET /tags/aabbccddeeff = %d, %+v", status, state)
+	}
+
+	// an unknown tag and an unsupported method
+	if status := get(http.MethodGet, "/tags/00:00:00:00:00:01", nil); status != http.StatusNotFound {
+		t.Errorf("GET unknown tag = %d", status)
+	}
+	if status := get(http.MethodPost, "/tags", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST /tags = %d", status)
+	}
+}
@@ -0,0 +1,61 @@
+package udp
+
+import (
+	"sync"
+)
+
+// batch constants
+const (
+	// DefaultBatchSize is the default number of datagrams read by a single syscall (on linux)
+	DefaultBatchSize = 32
+
+	// slabSize is the size of the memory slabs that the received packets are copied to
+	slabSize = 64 * 1024
+)
+
+// bufferPool pools the receive buffers of the sessions and connections (of the default maximum
+// datagram size)
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, DefaultMaxDatagramSize+1)
+		return &buf
+	},
+}
+
+// buffer returns a receive buffer one byte larger than the largest datagram, and the function that
+// releases it (pooled if of the default maximum datagram size)
+func (r *Receiver) buffer() ([]byte, func()) {
+	if r.options.MaxDatagramSize != DefaultMaxDatagramSize {
+		return make([]byte, r.options.MaxDatagramSize+1), func() {}
+	}
+	pooled := bufferPool.Get().(*[]byte)
+	return *pooled, func() {
+		bufferPool.Put(pooled)
+	}
+}
+
+// slabAllocator allocates the copies of the received packets from shared memory slabs instead of
+// allocating each packet on its own. The handlers may keep the packets, so a full slab is never
+// reused: a new slab is allocated, and the garbage collector frees the old slab once none of its
+// packets are referenced anymore
+type slabAllocator struct {
+	buf []byte
+}
+
+// alloc returns a newly allocated copy of the given packet bytes (with its capacity limited to its
+// length, so appending to it cannot overwrite the following packets)
+func (a *slabAllocator) alloc(data []byte) []byte {
+	// allocate a new slab if the packet does not fit
+	if len(data) > cap(a.buf)-len(a.buf) {
+		size := slabSize
+		if len(data) > size {
+			size = len(data)
+		}
+		a.buf = make([]byte, 0, size)
+	}
+
+	// append the packet to the slab
+	start := len(a.buf)
+	a.buf = append(a.buf, data...)
+	return a.buf[start:len(a.buf):len(a.buf)]
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package udp
+
+import (
+	"net"
+	"sync/atomic"
+
+	"golang.org/x/net/ipv4"
+)
+
+// serveBatch receives the udp datagrams in batches (a single recvmmsg syscall per batch)
+func (r *Receiver) serveBatch(socket *net.UDPConn) error {
+	// close the udp socket when done
+	defer socket.Close()
+
+	// create the batch receive buffers (one byte larger than the largest datagram to detect the
+	// truncated datagrams)
+	conn := ipv4.NewPacketConn(socket)
+	messages := make([]ipv4.Message, r.options.BatchSize)
+	for i := range messages {
+		messages[i].Buffers = [][]byte{make([]byte, r.options.MaxDatagramSize+1)}
+	}
+	slabs := &slabAllocator{}
+
+	// wait for received udp datagrams until closed
+	for {
+		// read the next batch of udp datagrams
+		n, err := conn.ReadBatch(messages, 0)
+		if err != nil {
+			if atomic.LoadInt32(&r.closed) == 1 {
+				return nil
+			}
+			return err
+		}
+
+		// handle every received datagram
+		for _, m := range messages[:n] {
+			remoteAddr, ok := m.Addr.(*net.UDPAddr)
+			if !ok {
+				continue
+			}
+			r.receive(slabs.alloc(m.Buffers[0][:m.N]), remoteAddr.IP, remoteAddr.Port)
+		}
+	}
+}
+
+// writeBatch sends the given datagrams on the connected udp socket (a single sendmmsg syscall per
+// batch, repeated until every datagram is sent)
+func writeBatch(conn *net.UDPConn, packets [][]byte) error {
+	// create the batch messages
+	messages := make([]ipv4.Message, len(packets))
+	for i, data := range packets {
+		messages[i].Buffers = [][]byte{data}
+	}
+
+	// send the messages
+	pc := ipv4.NewPacketConn(conn)
+	for len(messages) > 0 {
+		n, err := pc.WriteBatch(messages, 0)
+		if err != nil {
+			return err
+		}
+		messages = messages[n:]
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package udp
+
+import (
+	"net"
+)
+
+// serveBatch receives the udp datagrams one at a time (batched reads are only supported on linux)
+func (r *Receiver) serveBatch(socket *net.UDPConn) error {
+	return r.serveUDP(socket)
+}
+
+// writeBatch sends the given datagrams one at a time (batched writes are only supported on linux)
+func writeBatch(conn *net.UDPConn, packets [][]byte) error {
+	for _, data := range packets {
+		if _, err := conn.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSlabAllocator(t *testing.T) {
+	slabs := &slabAllocator{}
+	first := slabs.alloc([]byte("first"))
+	second := slabs.alloc([]byte("second"))
+
+	// appending to a copy does not overwrite the following copy
+	_ = append(first, "XXX"...)
+	if string(first) != "first" || string(second) != "second" {
+		t.Fatalf("copies = %q, %q", first, second)
+	}
+
+	// packets larger than a slab are still copied
+	if large := slabs.alloc(make([]byte, slabSize+1)); len(large) != slabSize+1 {
+		t.Fatalf("large copy length = %d", len(large))
+	}
+
+	// a full slab is not reused, so the kept packets are not overwritten
+	slabs.alloc(make([]byte, slabSize))
+	if string(first) != "first" || string(second) != "second" {
+		t.Fatalf("copies = %q, %q", first, second)
+	}
+}
+
+// startReceiver starts a receiver with the given batch size on a free port, counting the received
+// packets, and returns a sender to it
+func startReceiver(t testing.TB, batchSize int, received *uint64) (*Receiver, *Sender) {
+	r := NewReceiver(&ReceiverOptions{BatchSize: batchSize})
+	r.DataHandler(func(du *DataUpdate) {
+		atomic.AddUint64(received, 1)
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go r.Serve()
+
+	s := NewSender(&SenderOptions{
+		Host:  "127.0.0.1",
+		Port:  r.Addr().(*net.UDPAddr).Port,
+		Quiet: true,
+	})
+	return r, s
+}
+
+func TestBatchReceive(t *testing.T) {
+	// send more packets than a single batch
+	var received uint64
+	r, s := startReceiver(t, 8, &received)
+	defer r.Close()
+	defer s.Close()
+	packets := [][]byte{}
+	for i := 0; i < 20; i++ {
+		packets = append(packets, []byte(fmt.Sprintf("packet %d", i)))
+	}
+	if err := s.TransmitBatch(packets); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for every packet
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadUint64(&received) < 20 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadUint64(&received); n != 20 {
+		t.Fatalf("received %d packets", n)
+	}
+}
+
+// BenchmarkReceive measures the received packets per second with one datagram per syscall
+// (batch=1) and with batched recvmmsg syscalls (batch=32, linux only)
+func BenchmarkReceive(b *testing.B) {
+	for _, batchSize := range []int{1, DefaultBatchSize} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			var received uint64
+			r, s := startReceiver(b, batchSize, &received)
+			defer r.Close()
+			defer s.Close()
+
+			// send packets until the receiver has received b.N packets (the loopback drops the
+			// packets the receiver cannot keep up with)
+			packets := make([][]byte, DefaultBatchSize)
+			for i := range packets {
+				packets[i] = make([]byte, 64)
+			}
+			b.ResetTimer()
+			start := time.Now()
+			for atomic.LoadUint64(&received) < uint64(b.N) {
+				s.TransmitBatch(packets)
+			}
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "packets/s")
+		})
+	}
+}
+
+// BenchmarkTransmit measures the sent packets per second with one datagram per syscall and with
+// batched sendmmsg syscalls (linux only)
+func BenchmarkTransmit(b *testing.B) {
+	for _, batchSize := range []int{1, DefaultBatchSize} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			// send to a socket that never reads (the loopback drops the packets once full)
+			sink, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer sink.Close()
+			s := NewSender(&SenderOptions{
+				Host:  "127.0.0.1",
+				Port:  sink.LocalAddr().(*net.UDPAddr).Port,
+				Quiet: true,
+			})
+			defer s.Close()
+
+			packets := make([][]byte, batchSize)
+			for i := range packets {
+				packets[i] = make([]byte, 64)
+			}
+			b.ResetTimer()
+			start := time.Now()
+			for sent := 0; sent < b.N; sent += batchSize {
+				if err := s.TransmitBatch(packets); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "packets/s")
+		})
+	}
+}
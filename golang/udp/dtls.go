@@ -0,0 +1,135 @@
+package udp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/dtls/v2"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// dtlsHandshakeTimeout is the maximum time of a dtls handshake
+const dtlsHandshakeTimeout = 10 * time.Second
+
+// DTLSOptions provides the dtls 1.2 transport options, in pre-shared key mode (PSK) or certificate
+// mode (TLS)
+type DTLSOptions struct {
+	// PSK is the pre-shared key (psk mode)
+	PSK []byte
+
+	// PSKIdentity is the pre-shared key identity sent by the sender (psk mode)
+	PSKIdentity string
+
+	// TLS are the certificate files (certificate mode): the receiver needs a certificate and key (and
+	// requires a client certificate signed by the CA file if given), and the sender verifies the
+	// receiver certificate against the CA file (and sends its certificate if given)
+	TLS *util.TLSFiles
+}
+
+// config creates the dtls configuration of the receiver (server) or the sender (client)
+func (o *DTLSOptions) config(server bool, serverName string) (*dtls.Config, error) {
+	config := &dtls.Config{
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+		ConnectContextMaker: func() (context.Context, func()) {
+			return context.WithTimeout(context.Background(), dtlsHandshakeTimeout)
+		},
+	}
+
+	// pre-shared key mode
+	if len(o.PSK) > 0 {
+		psk := o.PSK
+		config.PSK = func(hint []byte) ([]byte, error) {
+			return psk, nil
+		}
+		config.PSKIdentityHint = []byte(o.PSKIdentity)
+		config.CipherSuites = []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256}
+		return config, nil
+	}
+
+	// certificate mode
+	if o.TLS == nil {
+		return nil, fmt.Errorf("DTLS needs a pre-shared key or certificate files")
+	}
+	tlsConfig, err := util.LoadTLSConfig(o.TLS)
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = tlsConfig.Certificates
+	config.InsecureSkipVerify = tlsConfig.InsecureSkipVerify
+	if server {
+		if len(config.Certificates) == 0 {
+			return nil, fmt.Errorf("DTLS receiver needs a certificate and key file")
+		}
+		if tlsConfig.RootCAs != nil {
+			config.ClientCAs = tlsConfig.RootCAs
+			config.ClientAuth = dtls.RequireAndVerifyClientCert
+		}
+	} else {
+		config.RootCAs = tlsConfig.RootCAs
+		config.ServerName = serverName
+	}
+	return config, nil
+}
+
+// dtlsTransport sends and receives the packets over dtls 1.2 sessions
+type dtlsTransport struct {
+	options *DTLSOptions
+}
+
+// Network returns the transport name
+func (t *dtlsTransport) Network() string {
+	return "dtls"
+}
+
+// Dial creates the udp socket to the given destination and performs the dtls handshake
+func (t *dtlsTransport) Dial(dst string, setup func(conn net.Conn) error) (net.Conn, error) {
+	conn, err := net.DialTimeout("udp", dst, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := setup(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(dst)
+	config, err := t.options.config(false, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	dtlsConn, err := dtls.Client(conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return dtlsConn, nil
+}
+
+// Listen listens to the dtls sessions on the given address
+func (t *dtlsTransport) Listen(addr string) (net.Listener, error) {
+	config, err := t.options.config(true, "")
+	if err != nil {
+		return nil, err
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return dtls.Listen("udp", udpAddr, config)
+}
+
+// WritePacket sends the given packet as a single dtls record
+func (t *dtlsTransport) WritePacket(conn net.Conn, data []byte) error {
+	_, err := conn.Write(data)
+	return err
+}
+
+// ReadPacket receives the next packet of the session (failing once the session is idle longer than
+// the idle timeout)
+func (t *dtlsTransport) ReadPacket(conn net.Conn, buf []byte) (int, error) {
+	conn.SetReadDeadline(time.Now().Add(dtlsIdleTimeout))
+	return conn.Read(buf)
+}
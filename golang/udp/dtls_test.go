@@ -0,0 +1,110 @@
+package udp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// loopback sends a packet from the given sender to the given receiver on the loopback interface
+func loopback(t *testing.T, receiverOptions *ReceiverOptions, senderOptions *SenderOptions) {
+	// start the receiver on a free port
+	received := make(chan []byte, 1)
+	r := NewReceiver(receiverOptions)
+	r.DataHandler(func(du *DataUpdate) {
+		received <- du.Data
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go r.Serve()
+
+	// send the packet
+	_, port, _ := net.SplitHostPort(r.Addr().String())
+	senderOptions.Host = "127.0.0.1"
+	senderOptions.Port, _ = strconv.Atoi(port)
+	senderOptions.Quiet = true
+	s := NewSender(senderOptions)
+	defer s.Close()
+	if err := s.Transmit([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the packet
+	select {
+	case data := <-received:
+		if string(data) != "hello" {
+			t.Errorf("received %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("packet not received")
+	}
+}
+
+// writeCertificate writes a self-signed 127.0.0.1 certificate and key, and returns their file paths
+func writeCertificate(t *testing.T) (string, string) {
+	// create the self-signed certificate
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// write the pem files
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestDTLSPSK(t *testing.T) {
+	psk := []byte("0123456789abcdef")
+	loopback(t,
+		&ReceiverOptions{DTLS: &DTLSOptions{PSK: psk}},
+		&SenderOptions{DTLS: &DTLSOptions{PSK: psk, PSKIdentity: "sender"}})
+}
+
+func TestDTLSCertificate(t *testing.T) {
+	// the sender verifies the receiver certificate against the ca file
+	certFile, keyFile := writeCertificate(t)
+	loopback(t,
+		&ReceiverOptions{DTLS: &DTLSOptions{TLS: &util.TLSFiles{CertFile: certFile, KeyFile: keyFile}}},
+		&SenderOptions{DTLS: &DTLSOptions{TLS: &util.TLSFiles{CAFile: certFile}}})
+}
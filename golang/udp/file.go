@@ -0,0 +1,382 @@
+package udp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// pcap file constants (the classic libpcap format, not pcapng)
+const (
+	pcapMagicMicros = 0xA1B2C3D4
+	pcapMagicNanos  = 0xA1B23C4D
+	pcapngMagic     = 0x0A0D0D0A
+
+	pcapHeaderSize = 24
+	pcapRecordSize = 16
+	pcapMaxRecord  = 256 * 1024
+
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+)
+
+// stdinPath is the file path that reads the packets from stdin (or writes them to stdout)
+const stdinPath = "-"
+
+// FileSource replays the packets of a pcap capture file, or of a hex file with one packet per line,
+// from a file path or stdin (the format is detected from the first bytes)
+type FileSource struct {
+	closed int32
+
+	options     *FileSourceOptions
+	dataHandler DataUpdateFunc
+}
+
+// FileSourceOptions provides the instance options
+type FileSourceOptions struct {
+	// Path is the pcap or hex file path ('-' reads stdin)
+	Path string
+
+	// Reader reads the packets from the given reader instead of the path
+	Reader io.Reader
+
+	// Port replays only the pcap udp datagrams sent to the given port (any port if zero)
+	Port int
+
+	// Pace replays the pcap packets with their captured spacing (as fast as possible otherwise)
+	Pace bool
+}
+
+// NewFileSource creates a new instance
+func NewFileSource(options *FileSourceOptions) *FileSource {
+	// apply the default option values
+	if options.Path == "" && options.Reader == nil {
+		options.Path = stdinPath
+	}
+
+	// return the new instance
+	return &FileSource{
+		options: options,
+	}
+}
+
+// DataHandler registers the update handler to call with every replayed packet
+func (f *FileSource) DataHandler(handler DataUpdateFunc) {
+	f.dataHandler = handler
+}
+
+// Serve replays the packets until the end of the file (or until closed)
+func (f *FileSource) Serve() error {
+	// open the file (or stdin)
+	reader := f.options.Reader
+	if reader == nil {
+		if f.options.Path == stdinPath {
+			reader = os.Stdin
+		} else {
+			file, err := os.Open(f.options.Path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			reader = file
+		}
+	}
+
+	// detect the file format
+	br := bufio.NewReader(reader)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(magic) < 4 {
+		return f.serveHex(br)
+	}
+	switch binary.BigEndian.Uint32(magic) {
+	case pcapMagicMicros, pcapMagicNanos:
+		return f.servePcap(br, binary.BigEndian)
+	case pcapngMagic:
+		return fmt.Errorf("pcapng files are not supported (convert with 'editcap -F pcap')")
+	}
+	switch binary.LittleEndian.Uint32(magic) {
+	case pcapMagicMicros, pcapMagicNanos:
+		return f.servePcap(br, binary.LittleEndian)
+	}
+	return f.serveHex(br)
+}
+
+// Close stops replaying the packets
+func (f *FileSource) Close() error {
+	atomic.StoreInt32(&f.closed, 1)
+	return nil
+}
+
+// serveHex replays the hex encoded packets, one per line (the whitespace and ':' separators are
+// ignored, and the '#' comments and blank lines are skipped)
+func (f *FileSource) serveHex(br *bufio.Reader) error {
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan() && atomic.LoadInt32(&f.closed) == 0; line++ {
+		// strip the comments and separators
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.Map(func(r rune) rune {
+			if r == ':' || r == ' ' || r == '\t' || r == '\r' {
+				return -1
+			}
+			return r
+		}, text)
+		if text == "" {
+			continue
+		}
+
+		// decode the packet
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			fmt.Printf("Error decoding hex packet on line %d (error = '%v')\n", line, err)
+			continue
+		}
+
+		// handle the packet
+		f.handle(&DataUpdate{
+			TS:       time.Now(),
+			RemoteIP: "127.0.0.1",
+			Data:     data,
+		})
+	}
+	return scanner.Err()
+}
+
+// servePcap replays the udp datagrams of the pcap capture
+func (f *FileSource) servePcap(br *bufio.Reader, order binary.ByteOrder) error {
+	// read the global header
+	header := make([]byte, pcapHeaderSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return err
+	}
+	nanos := order.Uint32(header) == pcapMagicNanos
+	linkType := order.Uint32(header[20:]) & 0xFFFF
+
+	// read every record
+	record := make([]byte, pcapRecordSize)
+	var lastTS time.Time
+	for atomic.LoadInt32(&f.closed) == 0 {
+		// read the record header
+		if _, err := io.ReadFull(br, record); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		sec, frac := order.Uint32(record), order.Uint32(record[4:])
+		if !nanos {
+			frac *= 1000
+		}
+		ts := time.Unix(int64(sec), int64(frac))
+		length := order.Uint32(record[8:])
+		if length > pcapMaxRecord {
+			return fmt.Errorf("invalid pcap record length (%d bytes)", length)
+		}
+
+		// read the captured frame
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(br, frame); err != nil {
+			return err
+		}
+
+		// extract the udp datagram (skipping every other frame)
+		du := udpDatagram(frame, linkType)
+		if du == nil || (f.options.Port != 0 && du.dstPort != f.options.Port) {
+			continue
+		}
+
+		// wait for the captured spacing if enabled
+		if f.options.Pace && !lastTS.IsZero() && ts.After(lastTS) {
+			time.Sleep(ts.Sub(lastTS))
+		}
+		lastTS = ts
+
+		// handle the datagram
+		du.TS = ts
+		f.handle(&du.DataUpdate)
+	}
+	return nil
+}
+
+// handle calls the update handler if registered (recovering from any panic)
+func (f *FileSource) handle(du *DataUpdate) {
+	if f.dataHandler != nil {
+		dispatch(f.dataHandler, du)
+	}
+}
+
+// capturedDatagram is a udp datagram extracted from a captured frame
+type capturedDatagram struct {
+	DataUpdate
+	dstPort int
+}
+
+// udpDatagram extracts the udp datagram of the given captured frame (nil if not udp)
+func udpDatagram(frame []byte, linkType uint32) *capturedDatagram {
+	// strip the link layer header
+	var packet []byte
+	switch linkType {
+	case linkTypeNull:
+		if len(frame) < 4 {
+			return nil
+		}
+		packet = frame[4:]
+
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		packet = frame[14:]
+		for etherType == 0x8100 || etherType == 0x88A8 {
+			// skip the vlan tags
+			if len(packet) < 4 {
+				return nil
+			}
+			etherType, packet = binary.BigEndian.Uint16(packet[2:]), packet[4:]
+		}
+
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return nil
+		}
+		packet = frame[16:]
+
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		packet = frame
+
+	default:
+		return nil
+	}
+	return ipDatagram(packet)
+}
+
+// ipDatagram extracts the udp datagram of the given ipv4 or ipv6 packet (nil if not udp, or a fragment)
+func ipDatagram(packet []byte) *capturedDatagram {
+	if len(packet) < 1 {
+		return nil
+	}
+
+	// parse the ip header
+	var src net.IP
+	var udp []byte
+	switch packet[0] >> 4 {
+	case 4:
+		headerLength := int(packet[0]&0x0F) * 4
+		if len(packet) < 20 || headerLength < 20 || len(packet) < headerLength || packet[9] != 17 {
+			return nil
+		}
+		if binary.BigEndian.Uint16(packet[6:])&0x3FFF != 0 {
+			// skip the fragments
+			return nil
+		}
+		src = net.IP(packet[12:16])
+		udp = packet[headerLength:]
+
+	case 6:
+		if len(packet) < 40 || packet[6] != 17 {
+			return nil
+		}
+		src = net.IP(packet[8:24])
+		udp = packet[40:]
+
+	default:
+		return nil
+	}
+
+	// parse the udp header
+	if len(udp) < 8 {
+		return nil
+	}
+	length := int(binary.BigEndian.Uint16(udp[4:]))
+	if length < 8 || length > len(udp) {
+		length = len(udp)
+	}
+	data := make([]byte, length-8)
+	copy(data, udp[8:length])
+	return &capturedDatagram{
+		DataUpdate: DataUpdate{
+			RemoteIP:   src.String(),
+			RemotePort: int(binary.BigEndian.Uint16(udp)),
+			Data:       data,
+		},
+		dstPort: int(binary.BigEndian.Uint16(udp[2:])),
+	}
+}
+
+// FileSender writes every transmitted packet as a hex line to a file or stdout (readable by the
+// file source)
+type FileSender struct {
+	options *FileSenderOptions
+	writer  io.Writer
+	file    *os.File
+}
+
+// FileSenderOptions provides the instance options
+type FileSenderOptions struct {
+	// Path is the hex file path, appended to if it exists ('-' writes to stdout)
+	Path string
+
+	// Writer writes the packets to the given writer instead of the path
+	Writer io.Writer
+}
+
+// NewFileSender creates a new instance (opening the file)
+func NewFileSender(options *FileSenderOptions) (*FileSender, error) {
+	// apply the default option values
+	if options.Path == "" && options.Writer == nil {
+		options.Path = stdinPath
+	}
+
+	// open the file (or stdout)
+	f := &FileSender{
+		options: options,
+		writer:  options.Writer,
+	}
+	if f.writer == nil {
+		if options.Path == stdinPath {
+			f.writer = os.Stdout
+		} else {
+			file, err := os.OpenFile(options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			f.file = file
+			f.writer = file
+		}
+	}
+
+	// return the new instance
+	return f, nil
+}
+
+// Transmit writes the given packet bytes as a hex line
+func (f *FileSender) Transmit(data []byte) error {
+	_, err := fmt.Fprintln(f.writer, hex.EncodeToString(data))
+	return err
+}
+
+// Close closes the file
+func (f *FileSender) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
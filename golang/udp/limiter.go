@@ -0,0 +1,85 @@
+package udp
+
+import (
+	"sync"
+	"time"
+)
+
+// limiterSweepInterval is the interval between the removals of the idle token buckets
+const limiterSweepInterval = time.Minute
+
+// rateLimiter is a set of token buckets, one per key (e.g. source ip-address or tag mac-address)
+type rateLimiter struct {
+	mutex     sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket holds the tokens available to a single key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter refilling 'rate' tokens per second up to 'burst' tokens per key
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	// the burst defaults to one second of tokens (and at least a single token)
+	if burst <= 0 {
+		burst = int(rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	// return the new instance
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// allow takes a token from the bucket of the given key at the given time, and returns whether
+// a token was available
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// remove the full buckets periodically (so spoofed keys cannot grow the map forever)
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		for k, b := range l.buckets {
+			if b.refill(now, l.rate, l.burst) >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	// get the key bucket (or create a full bucket if this is the first packet)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	// take a token if available
+	if b.refill(now, l.rate, l.burst) < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens accumulated since the last refill and returns the available tokens
+func (b *tokenBucket) refill(now time.Time, rate float64, burst float64) float64 {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+	return b.tokens
+}
@@ -0,0 +1,69 @@
+package udp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Unix(1700000000, 0)
+
+	// the burst is allowed, then the bucket is empty
+	for i := 0; i < 3; i++ {
+		if !l.allow("a", now) {
+			t.Fatalf("packet #%d not allowed", i+1)
+		}
+	}
+	if l.allow("a", now) {
+		t.Fatal("packet allowed beyond the burst")
+	}
+
+	// other keys have their own bucket
+	if !l.allow("b", now) {
+		t.Fatal("packet of another key not allowed")
+	}
+
+	// the bucket refills at the rate
+	if !l.allow("a", now.Add(500*time.Millisecond)) || l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Fatal("bucket did not refill a single token after 0.5 seconds")
+	}
+
+	// the idle full buckets are removed
+	l.allow("c", now.Add(time.Hour))
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d, want 1", len(l.buckets))
+	}
+}
+
+func TestReceiverCheck(t *testing.T) {
+	r := NewReceiver(&ReceiverOptions{
+		Allow:    []string{"10.0.0.0/8", "192.168.1.1"},
+		Deny:     []string{"10.0.0.66"},
+		TagRate:  1,
+		TagBurst: 1,
+	})
+	if err := r.init(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	data := make([]byte, 34)
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.1.2.3", ""},
+		{"10.1.2.4", TagRateDropReason},
+		{"10.0.0.66", DeniedDropReason},
+		{"172.16.0.1", DeniedDropReason},
+		{"192.168.1.1", TagRateDropReason},
+	}
+	for _, test := range tests {
+		du := &DataUpdate{TS: now, RemoteIP: test.ip, Data: data}
+		if reason := r.check(du, net.ParseIP(test.ip)); reason != test.want {
+			t.Errorf("check(%s) = '%s', want '%s'", test.ip, reason, test.want)
+		}
+	}
+}
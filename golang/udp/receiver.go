@@ -1,21 +1,112 @@
 package udp
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/ccx"
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// dropped packet reason constants
+const (
+	DeniedDropReason     = "denied"
+	SourceRateDropReason = "source_rate_limited"
+	TagRateDropReason    = "tag_rate_limited"
+	TruncatedDropReason  = "truncated"
+)
+
+// dtlsIdleTimeout is how long an idle dtls session is kept open
+const dtlsIdleTimeout = 5 * time.Minute
+
+// the backoff range of the retried connection accept errors
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
 )
 
 // Receiver is the UDP server instance
 type Receiver struct {
+	// the atomic counters are first to keep their 64-bit alignment on 32-bit platforms
+	denied            uint64
+	sourceRateLimited uint64
+	tagRateLimited    uint64
+	truncated         uint64
+	closed            int32
+
 	options     *ReceiverOptions
+	mutex       sync.Mutex
+	transport   Transport
+	sockets     []*net.UDPConn
+	listener    net.Listener
 	dataHandler DataUpdateFunc
+	dropHandler DropFunc
+	allow       []*net.IPNet
+	deny        []*net.IPNet
+	sourceLimit *rateLimiter
+	tagLimit    *rateLimiter
 }
 
 // ReceiverOptions provides the instance options
 type ReceiverOptions struct {
 	Port int
+
+	// Allow accepts only the datagrams from the given cidr networks or ip-addresses (any source if empty)
+	Allow []string
+
+	// Deny drops the datagrams from the given cidr networks or ip-addresses (checked before Allow)
+	Deny []string
+
+	// SourceRate limits the datagrams per second accepted from each source ip-address (unlimited if zero),
+	// allowing bursts of up to SourceBurst datagrams (one second of datagrams if zero)
+	SourceRate  float64
+	SourceBurst int
+
+	// TagRate limits the datagrams per second accepted from each tag mac-address (unlimited if zero),
+	// allowing bursts of up to TagBurst datagrams (one second of datagrams if zero)
+	TagRate  float64
+	TagBurst int
+
+	// ReadBuffer is the kernel receive buffer size of the udp sockets (SO_RCVBUF, the kernel default if
+	// zero), a warning is logged if the kernel clamps it
+	ReadBuffer int
+
+	// Listeners is the number of udp sockets listening on the port with SO_REUSEPORT, each read by its
+	// own goroutine (a single socket if zero, the update handler is still called one packet at a time)
+	Listeners int
+
+	// MaxDatagramSize is the size of the largest accepted datagram (the larger datagrams are dropped as
+	// truncated, DefaultMaxDatagramSize if zero)
+	MaxDatagramSize int
+
+	// BatchSize is the number of udp datagrams read by a single recvmmsg syscall on linux (one datagram
+	// per syscall if 1, DefaultBatchSize if zero)
+	BatchSize int
+
+	// Transport is the udp (default), tcp, or tls transport name (the stream transports receive length
+	// prefixed packets, see the transport constants)
+	Transport string
+
+	// TLS are the receiver certificate files of the tls transport
+	TLS *util.TLSFiles
+
+	// DTLS receives the packets over dtls 1.2 sessions instead of plain udp (disabled if nil)
+	DTLS *DTLSOptions
+}
+
+// ReceiverStats defines the dropped datagram counters
+type ReceiverStats struct {
+	Denied            uint64 `json:"denied"`
+	SourceRateLimited uint64 `json:"source_rate_limited"`
+	TagRateLimited    uint64 `json:"tag_rate_limited"`
+	Truncated         uint64 `json:"truncated"`
 }
 
 // DataUpdate defines the UDP data update structure passed to the registered data handler
@@ -29,8 +120,25 @@ type DataUpdate struct {
 // DataUpdateFunc is the callback function type used to notify when UDP data is received
 type DataUpdateFunc func(du *DataUpdate)
 
+// DropFunc is the callback function type used to notify when UDP data is dropped (with the drop reason)
+type DropFunc func(du *DataUpdate, reason string)
+
 // NewReceiver creates a new instance
 func NewReceiver(options *ReceiverOptions) *Receiver {
+	// apply the default option values
+	if options.Transport == "" {
+		options.Transport = TransportUDP
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.Listeners <= 0 {
+		options.Listeners = 1
+	}
+	if options.MaxDatagramSize <= 0 {
+		options.MaxDatagramSize = DefaultMaxDatagramSize
+	}
+
 	// return the new instance
 	return &Receiver{
 		options: options,
@@ -43,55 +151,389 @@ func (r *Receiver) DataHandler(handler DataUpdateFunc) {
 	r.dataHandler = handler
 }
 
-// Run starts the UDP receiver instance
-func (r *Receiver) Run() {
-	fmt.Printf("Starting UDP receiver listening on port '%d'\n", r.options.Port)
+// DropHandler registers the handler to call when UDP data is dropped by the source filters or rate limits
+func (r *Receiver) DropHandler(handler DropFunc) {
+	// save the drop handler
+	r.dropHandler = handler
+}
 
-	// start listening to udp packet
-	socket, err := net.ListenUDP("udp", &net.UDPAddr{
-		IP:   net.IPv4(0, 0, 0, 0),
-		Port: r.options.Port,
-	})
+// Stats returns the dropped datagram counters
+func (r *Receiver) Stats() ReceiverStats {
+	return ReceiverStats{
+		Denied:            atomic.LoadUint64(&r.denied),
+		SourceRateLimited: atomic.LoadUint64(&r.sourceRateLimited),
+		TagRateLimited:    atomic.LoadUint64(&r.tagRateLimited),
+		Truncated:         atomic.LoadUint64(&r.truncated),
+	}
+}
 
-	// if an error occurred
-	if err != nil {
+// Network returns the transport name
+func (r *Receiver) Network() string {
+	return r.options.Transport
+}
+
+// Run starts the UDP receiver instance (exiting the process on any error)
+func (r *Receiver) Run() {
+	fmt.Printf("Starting %s receiver listening on port '%d'\n", strings.ToUpper(r.Network()), r.options.Port)
+
+	// start listening to udp packets
+	if err := r.Start(); err != nil {
 		// log the error and exit the process now
 		fmt.Printf("Error starting UDP receiver listening to UDP port '%d' (error = '%v')\n\n",
 			r.options.Port, err)
 		os.Exit(1)
 	}
 
+	// wait for received udp packets until commanded to quit
+	if err := r.Serve(); err != nil {
+		// log the error and exit the process now
+		fmt.Printf("Error occurred while receiving UDP packet (error = '%v')\n", err)
+		os.Exit(1)
+	}
+}
+
+// Start creates the source filters and rate limits and starts listening on the configured port
+// (the packets are received by Serve)
+func (r *Receiver) Start() error {
+	// create the source filters and rate limits
+	if err := r.init(); err != nil {
+		return err
+	}
+
+	// listen to the tcp or tls connections (or the dtls sessions) if enabled
+	addr := &net.UDPAddr{
+		IP:   net.IPv4(0, 0, 0, 0),
+		Port: r.options.Port,
+	}
+	transport, err := NewTransport(r.options.Transport, r.options.TLS, r.options.DTLS)
+	if err != nil {
+		return err
+	}
+	if r.options.Listeners > 1 && transport.Network() != TransportUDP {
+		return fmt.Errorf("Multiple listeners are only supported by the plain udp transport")
+	}
+	r.transport = transport
+	listener, err := transport.Listen(addr.String())
+	if err != nil {
+		return err
+	}
+	if listener != nil {
+		r.listener = listener
+		return nil
+	}
+
+	// otherwise listen to the plain udp packets (on several SO_REUSEPORT sockets if enabled)
+	config := &net.ListenConfig{}
+	if r.options.Listeners > 1 {
+		config.Control = reusePortControl
+	}
+	for i := 0; i < r.options.Listeners; i++ {
+		conn, err := config.ListenPacket(context.Background(), "udp", addr.String())
+		if err != nil {
+			r.Close()
+			return err
+		}
+		socket := conn.(*net.UDPConn)
+		r.sockets = append(r.sockets, socket)
+
+		// set the kernel receive buffer size if given (warning once if clamped)
+		if r.options.ReadBuffer > 0 {
+			if err := setReadBuffer(socket, r.options.ReadBuffer, i == 0); err != nil {
+				r.Close()
+				return err
+			}
+		}
+
+		// the following sockets listen on the port of the first socket (e.g. chosen by the kernel)
+		addr.Port = socket.LocalAddr().(*net.UDPAddr).Port
+	}
+	return nil
+}
+
+// Addr returns the local listening address (once started)
+func (r *Receiver) Addr() net.Addr {
+	if r.listener != nil {
+		return r.listener.Addr()
+	}
+	if len(r.sockets) > 0 {
+		return r.sockets[0].LocalAddr()
+	}
+	return nil
+}
+
+// Serve receives the packets until the receiver is closed (starting the receiver if not started yet)
+func (r *Receiver) Serve() error {
+	if r.listener == nil && len(r.sockets) == 0 {
+		if err := r.Start(); err != nil {
+			return err
+		}
+	}
+	if r.listener != nil {
+		return r.serveListener()
+	}
+
+	// read every udp socket with its own goroutine (until any of them fails)
+	errs := make(chan error, len(r.sockets))
+	for _, socket := range r.sockets {
+		go func(socket *net.UDPConn) {
+			if r.options.BatchSize > 1 {
+				errs <- r.serveBatch(socket)
+			} else {
+				errs <- r.serveUDP(socket)
+			}
+		}(socket)
+	}
+	var firstErr error
+	for range r.sockets {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			r.Close()
+		}
+	}
+	return firstErr
+}
+
+// Close stops receiving packets
+func (r *Receiver) Close() error {
+	atomic.StoreInt32(&r.closed, 1)
+	if r.listener != nil {
+		return r.listener.Close()
+	}
+	var err error
+	for _, socket := range r.sockets {
+		if closeErr := socket.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (r *Receiver) serveUDP(socket *net.UDPConn) error {
 	// close the udp socket when done
 	defer socket.Close()
 
-	// create the udp receive buffer
-	buf := make([]byte, 2048)
+	// create the udp receive buffer (one byte larger than the largest datagram to detect the truncated
+	// datagrams)
+	buf := make([]byte, r.options.MaxDatagramSize+1)
+	slabs := &slabAllocator{}
 
-	// wait for received udp packets until commanded to quit
+	// wait for received udp packets until closed
 	for {
 		// read the next udp packet
 		numBytes, remoteAddr, err := socket.ReadFromUDP(buf)
 		if err != nil {
-			// log the error and exit the process now
-			fmt.Printf("Error occurred while receiving UDP packet (error = '%v'", err)
-			os.Exit(1)
+			if atomic.LoadInt32(&r.closed) == 1 {
+				return nil
+			}
+			return err
 		}
 
-		// copy the received bytes from the buffer
-		data := make([]byte, numBytes)
-		copy(data, buf)
-
-		// get the current time
-		now := time.Now()
-
-		// call the update handler if registered
-		if r.dataHandler != nil {
-			r.dataHandler(&DataUpdate{
-				TS:         now,
-				RemoteIP:   remoteAddr.IP.String(),
-				RemotePort: remoteAddr.Port,
-				Data:       data,
-			})
+		// handle a copy of the received bytes
+		r.receive(slabs.alloc(buf[:numBytes]), remoteAddr.IP, remoteAddr.Port)
+	}
+}
+
+func (r *Receiver) serveListener() error {
+	// close the listener when done
+	defer r.listener.Close()
+
+	// accept every dtls session (or tcp connection) until closed
+	delay := time.Duration(0)
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&r.closed) == 1 {
+				return nil
+			}
+
+			// a failed handshake only affects its own session, and the persistent errors (e.g. too many
+			// open files) are retried with a backoff instead of spinning (as the net/http server does)
+			if delay == 0 {
+				delay = minAcceptDelay
+			} else if delay *= 2; delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+			fmt.Printf("Error accepting %s connection, retrying in %v (error = '%v')\n",
+				strings.ToUpper(r.Network()), delay, err)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		// receive the packets of the session
+		go r.serveConn(conn)
+	}
+}
+
+func (r *Receiver) serveConn(conn net.Conn) {
+	// close the session when done
+	defer conn.Close()
+
+	// get a pooled receive buffer (one byte larger than the largest datagram)
+	ip, port := remoteIPPort(conn.RemoteAddr())
+	buf, release := r.buffer()
+	defer release()
+	slabs := &slabAllocator{}
+	stream := isStream(r.transport.Network())
+
+	// wait for received packets until the session is closed (or the dtls session is idle, the dead tcp
+	// connections are detected by the tcp keep-alives)
+	for {
+		numBytes, err := r.transport.ReadPacket(conn, buf)
+		if err != nil {
+			if stream && err != io.EOF && atomic.LoadInt32(&r.closed) == 0 {
+				fmt.Printf("Error receiving from %s connection '%s' (error = '%v')\n",
+					strings.ToUpper(r.Network()), conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		// handle a copy of the received bytes
+		r.receive(slabs.alloc(buf[:numBytes]), ip, port)
+	}
+}
+
+// receive checks the given received packet against the source filters and rate limits, and calls the
+// update handler (one packet at a time, even with concurrent sessions)
+func (r *Receiver) receive(data []byte, ip net.IP, port int) {
+	r.accept(&DataUpdate{
+		TS:         time.Now(),
+		RemoteIP:   ip.String(),
+		RemotePort: port,
+		Data:       data,
+	}, ip)
+}
+
+// Replay delivers the packets of the given source (e.g. a pcap or hex file) through the source
+// filters, rate limits, and update handler of the receiver instead of its sockets
+func (r *Receiver) Replay(source PacketSource) error {
+	// create the source filters and rate limits
+	if err := r.init(); err != nil {
+		return err
+	}
+
+	// check and handle every replayed packet (at its replayed time and source address)
+	source.DataHandler(func(du *DataUpdate) {
+		r.accept(du, net.ParseIP(du.RemoteIP))
+	})
+	return source.Serve()
+}
+
+// accept checks the given packet against the source filters and rate limits, and calls the update
+// handler if accepted
+func (r *Receiver) accept(du *DataUpdate, ip net.IP) {
+	// drop the truncated datagrams, and the datagrams rejected by the source filters or rate limits
+	if len(du.Data) > r.options.MaxDatagramSize {
+		du.Data = du.Data[:r.options.MaxDatagramSize]
+		r.drop(du, TruncatedDropReason)
+		return
+	}
+	if reason := r.check(du, ip); reason != "" {
+		r.drop(du, reason)
+		return
+	}
+
+	// call the update handler if registered
+	if r.dataHandler != nil {
+		r.mutex.Lock()
+		dispatch(r.dataHandler, du)
+		r.mutex.Unlock()
+	}
+}
+
+// dispatch calls the given data handler, recovering from any panic so that a single malformed
+// datagram cannot take down the receiver process
+func dispatch(handler DataUpdateFunc, du *DataUpdate) {
+	defer func() {
+		if err := recover(); err != nil {
+			// log the error and the offending datagram, then continue receiving
+			fmt.Printf("\nERROR: UDP data handler panicked on datagram from '%s:%d' (error = '%v', data = %x)\n\n",
+				du.RemoteIP, du.RemotePort, err, du.Data)
+		}
+	}()
+
+	// call the update handler
+	handler(du)
+}
+
+// init parses the source filters and creates the rate limiters
+func (r *Receiver) init() error {
+	var err error
+	if r.allow, err = parseNetworks(r.options.Allow); err != nil {
+		return err
+	}
+	if r.deny, err = parseNetworks(r.options.Deny); err != nil {
+		return err
+	}
+	if r.options.SourceRate > 0 {
+		r.sourceLimit = newRateLimiter(r.options.SourceRate, r.options.SourceBurst)
+	}
+	if r.options.TagRate > 0 {
+		r.tagLimit = newRateLimiter(r.options.TagRate, r.options.TagBurst)
+	}
+	return nil
+}
+
+// check returns the reason the given datagram is dropped (or an empty string if accepted)
+func (r *Receiver) check(du *DataUpdate, ip net.IP) string {
+	// check the source denylist and allowlist
+	if containsIP(r.deny, ip) || (len(r.allow) > 0 && !containsIP(r.allow, ip)) {
+		return DeniedDropReason
+	}
+
+	// check the source rate limit
+	if r.sourceLimit != nil && !r.sourceLimit.allow(du.RemoteIP, du.TS) {
+		return SourceRateDropReason
+	}
+
+	// check the tag rate limit (the datagrams too short to include a tag mac-address are not limited)
+	if r.tagLimit != nil && len(du.Data) >= ccx.TagMACOffset+6 {
+		tag := string(du.Data[ccx.TagMACOffset : ccx.TagMACOffset+6])
+		if !r.tagLimit.allow(tag, du.TS) {
+			return TagRateDropReason
+		}
+	}
+
+	// the datagram is accepted
+	return ""
+}
+
+// drop counts the given dropped datagram and calls the drop handler if registered
+func (r *Receiver) drop(du *DataUpdate, reason string) {
+	switch reason {
+	case DeniedDropReason:
+		atomic.AddUint64(&r.denied, 1)
+	case SourceRateDropReason:
+		atomic.AddUint64(&r.sourceRateLimited, 1)
+	case TagRateDropReason:
+		atomic.AddUint64(&r.tagRateLimited, 1)
+	case TruncatedDropReason:
+		atomic.AddUint64(&r.truncated, 1)
+	}
+	if r.dropHandler != nil {
+		r.dropHandler(du, reason)
+	}
+}
+
+// parseNetworks parses the given cidr networks or ip-addresses
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, v := range values {
+		network, err := util.ParseNetwork(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// containsIP returns whether any of the given networks contains the given ip-address
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
 		}
 	}
+	return false
 }
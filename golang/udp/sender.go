@@ -4,49 +4,212 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
 )
 
+// dialTimeout is the maximum time to connect the tcp and tls transports
+const dialTimeout = 10 * time.Second
+
 // Sender is the UDP transmitter instance
 type Sender struct {
-	options *SenderOptions
+	options   *SenderOptions
+	mutex     sync.Mutex
+	transport Transport
+	conn      net.Conn
+	lastSent  time.Time
+	warned    bool
 }
 
 // SenderOptions provides the instance options
 type SenderOptions struct {
-	Host string
-	Port int
+	Host  string
+	Port  int
+	Quiet bool // skips logging each packet (errors are still returned)
+
+	// Transport is the udp (default), tcp, or tls transport name (the stream transports send length
+	// prefixed packets over a single connection, see the transport constants)
+	Transport string
+
+	// TLS are the certificate files of the tls transport (the system CAs verify the receiver if empty)
+	TLS *util.TLSFiles
+
+	// WriteBuffer is the kernel send buffer size of the socket (SO_SNDBUF, the kernel default if zero),
+	// a warning is logged if the kernel clamps it
+	WriteBuffer int
+
+	// DSCP marks the sent packets with the given differentiated services code point (0-63, e.g. 46 for
+	// expedited forwarding, unmarked if zero)
+	DSCP int
+
+	// DTLS sends the packets over a dtls 1.2 session instead of plain udp (disabled if nil)
+	DTLS *DTLSOptions
 }
 
 // NewSender creates a new instance
 func NewSender(options *SenderOptions) *Sender {
+	// apply the default option values
+	if options.Transport == "" {
+		options.Transport = TransportUDP
+	}
+
 	// return the new instance
 	return &Sender{
 		options: options,
 	}
 }
 
+// Destination returns the 'host:port' destination address
+func (s *Sender) Destination() string {
+	return net.JoinHostPort(s.options.Host, strconv.Itoa(s.options.Port))
+}
+
+// Network returns the transport name
+func (s *Sender) Network() string {
+	return s.options.Transport
+}
+
 // Transmit sends the given message as a UDP packet to the configured destination
-func (s *Sender) Transmit(data []byte) {
-	dst := fmt.Sprintf("%s:%d", s.options.Host, s.options.Port)
-	log.Printf("Sending udp packet to '%s' (%d bytes)", dst, len(data))
+func (s *Sender) Transmit(data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	// create the udp socket
-	conn, err := net.Dial("udp", dst)
-	if err != nil {
-		// log the error and return now
-		fmt.Printf("Error creating udp socket (error = '%v')\n", err)
-		return
+	dst := s.Destination()
+	if !s.options.Quiet {
+		log.Printf("Sending udp packet to '%s' (%d bytes)", dst, len(data))
 	}
 
-	// close the udp socket when finished
-	defer conn.Close()
-
 	// send the udp packet
-	_, err = conn.Write(data)
-	if err != nil {
-		// log the error and return now
-		log.Printf("Error sending udp packet (error = '%v')", err)
-	} else {
+	if err := s.send([][]byte{data}); err != nil {
+		return err
+	}
+	if !s.options.Quiet {
 		log.Printf("Successfully sent UDP packet to '%s' (%d bytes)\n", dst, len(data))
 	}
+
+	// return successfully
+	return nil
+}
+
+// TransmitBatch sends the given messages as UDP packets to the configured destination (with a single
+// sendmmsg syscall per batch on linux, when not using the tcp, tls, or dtls transports)
+func (s *Sender) TransmitBatch(packets [][]byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	dst := s.Destination()
+	if !s.options.Quiet {
+		log.Printf("Sending %d udp packets to '%s'", len(packets), dst)
+	}
+
+	// send the udp packets
+	if err := s.send(packets); err != nil {
+		return err
+	}
+	if !s.options.Quiet {
+		log.Printf("Successfully sent %d UDP packets to '%s'\n", len(packets), dst)
+	}
+
+	// return successfully
+	return nil
+}
+
+// send sends the given packets, creating the udp socket (or the session) if needed
+func (s *Sender) send(packets [][]byte) error {
+	// reopen the dtls sessions that the receiver has closed for being idle
+	if s.conn != nil && s.options.DTLS != nil && time.Since(s.lastSent) >= dtlsIdleTimeout {
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	// create the udp socket (reused by the following packets)
+	if s.conn == nil {
+		conn, err := s.dial(s.Destination())
+		if err != nil {
+			// log the error and return now
+			if !s.options.Quiet {
+				fmt.Printf("Error creating udp socket (error = '%v')\n", err)
+			}
+			return err
+		}
+		s.conn = conn
+	}
+
+	// send the udp packets (batched on the plain udp sockets, or the length prefixed packets over the
+	// stream)
+	var err error
+	if udpConn, ok := s.conn.(*net.UDPConn); ok && len(packets) > 1 {
+		err = writeBatch(udpConn, packets)
+	} else {
+		for _, data := range packets {
+			if err = s.transport.WritePacket(s.conn, data); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		// log the error and recreate the udp socket with the next packet
+		if !s.options.Quiet {
+			log.Printf("Error sending udp packet (error = '%v')", err)
+		}
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	s.lastSent = time.Now()
+
+	// return successfully
+	return nil
+}
+
+// dial creates the udp socket (or the dtls session, or the tcp or tls connection) to the given destination
+func (s *Sender) dial(dst string) (net.Conn, error) {
+	// create the transport (validated once)
+	if s.transport == nil {
+		transport, err := NewTransport(s.options.Transport, s.options.TLS, s.options.DTLS)
+		if err != nil {
+			return nil, err
+		}
+		s.transport = transport
+	}
+
+	// create the socket, setting its options before any tls or dtls handshake
+	return s.transport.Dial(dst, s.setup)
+}
+
+// setup sets the socket options of the given udp or tcp socket
+func (s *Sender) setup(conn net.Conn) error {
+	// set the kernel send buffer size if given
+	if s.options.WriteBuffer > 0 {
+		if bc, ok := conn.(bufferedConn); ok {
+			if err := setWriteBuffer(bc, s.options.WriteBuffer, !s.warned); err != nil {
+				return err
+			}
+			s.warned = true
+		}
+	}
+
+	// mark the packets with the dscp if given
+	if s.options.DSCP != 0 {
+		if err := setDSCP(conn, s.options.DSCP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the udp socket (or the dtls session, or the tcp or tls connection)
+func (s *Sender) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
 }
@@ -0,0 +1,58 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DefaultMaxDatagramSize is the default size of the largest datagram accepted by the receiver
+const DefaultMaxDatagramSize = 2048
+
+// bufferedConn is a udp or tcp socket with configurable kernel buffers
+type bufferedConn interface {
+	SetReadBuffer(bytes int) error
+	SetWriteBuffer(bytes int) error
+	SyscallConn() (syscall.RawConn, error)
+}
+
+// setReadBuffer sets the kernel receive buffer size of the given socket (SO_RCVBUF), warning if the
+// kernel clamps the requested size
+func setReadBuffer(conn bufferedConn, bytes int, warn bool) error {
+	if err := conn.SetReadBuffer(bytes); err != nil {
+		return err
+	}
+	if actual, err := socketBufferSize(conn, syscall.SO_RCVBUF); warn && err == nil && actual < bytes {
+		fmt.Printf("Warning: the kernel clamped the %d bytes socket receive buffer to %d bytes (see the 'net.core.rmem_max' sysctl)\n",
+			bytes, actual)
+	}
+	return nil
+}
+
+// setWriteBuffer sets the kernel send buffer size of the given socket (SO_SNDBUF), warning if the
+// kernel clamps the requested size
+func setWriteBuffer(conn bufferedConn, bytes int, warn bool) error {
+	if err := conn.SetWriteBuffer(bytes); err != nil {
+		return err
+	}
+	if actual, err := socketBufferSize(conn, syscall.SO_SNDBUF); warn && err == nil && actual < bytes {
+		fmt.Printf("Warning: the kernel clamped the %d bytes socket send buffer to %d bytes (see the 'net.core.wmem_max' sysctl)\n",
+			bytes, actual)
+	}
+	return nil
+}
+
+// setDSCP marks every packet sent on the given socket with the given differentiated services code
+// point (the 6 high bits of the ipv4 tos or ipv6 traffic class byte)
+func setDSCP(conn net.Conn, dscp int) error {
+	if dscp < 0 || dscp > 63 {
+		return fmt.Errorf("Invalid DSCP '%d' (expected 0-63)", dscp)
+	}
+	if ip, _ := remoteIPPort(conn.RemoteAddr()); ip.To4() != nil {
+		return ipv4.NewConn(conn).SetTOS(dscp << 2)
+	}
+	return ipv6.NewConn(conn).SetTrafficClass(dscp << 2)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package udp
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// reusePortControl fails since SO_REUSEPORT is not supported on this platform
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on this platform")
+}
+
+// socketBufferSize fails since the kernel buffer sizes cannot be read on this platform (so no clamp
+// warning is logged)
+func socketBufferSize(conn bufferedConn, option int) (int, error) {
+	return 0, fmt.Errorf("socket buffer sizes cannot be read on this platform")
+}
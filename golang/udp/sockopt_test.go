@@ -0,0 +1,92 @@
+package udp
+
+import (
+	"net"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// waitFor waits until the given condition is true (or fails after 5 seconds)
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met after 5 seconds")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReusePortListeners(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT load balancing is only tested on linux")
+	}
+
+	// start the receiver with several sockets on the same port
+	var received uint64
+	r := NewReceiver(&ReceiverOptions{Listeners: 3, ReadBuffer: 64 * 1024})
+	r.DataHandler(func(du *DataUpdate) {
+		atomic.AddUint64(&received, 1)
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go r.Serve()
+	if len(r.sockets) != 3 {
+		t.Fatalf("%d sockets", len(r.sockets))
+	}
+
+	// send from several source ports (balanced between the sockets)
+	port := r.Addr().(*net.UDPAddr).Port
+	for i := 0; i < 10; i++ {
+		s := NewSender(&SenderOptions{Host: "127.0.0.1", Port: port, Quiet: true})
+		s.Transmit([]byte("packet"))
+		s.Close()
+	}
+	waitFor(t, func() bool { return atomic.LoadUint64(&received) == 10 })
+}
+
+func TestTruncatedDatagram(t *testing.T) {
+	// the datagrams larger than the maximum size are dropped
+	var received uint64
+	r := NewReceiver(&ReceiverOptions{MaxDatagramSize: 16})
+	r.DataHandler(func(du *DataUpdate) {
+		atomic.AddUint64(&received, 1)
+	})
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go r.Serve()
+
+	s := NewSender(&SenderOptions{Host: "127.0.0.1", Port: r.Addr().(*net.UDPAddr).Port, Quiet: true})
+	defer s.Close()
+	s.Transmit(make([]byte, 17))
+	s.Transmit(make([]byte, 16))
+	waitFor(t, func() bool { return atomic.LoadUint64(&received) == 1 && r.Stats().Truncated == 1 })
+}
+
+func TestSenderSocketOptions(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the socket options are only tested on linux")
+	}
+
+	// the send buffer and dscp are set on the socket
+	s := NewSender(&SenderOptions{Host: "127.0.0.1", Port: 9, Quiet: true, WriteBuffer: 32 * 1024, DSCP: 46})
+	defer s.Close()
+	if err := s.Transmit([]byte("packet")); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := socketBufferSize(s.conn.(bufferedConn), syscall.SO_SNDBUF); err != nil || size < 32*1024 {
+		t.Errorf("send buffer = %d, %v", size, err)
+	}
+	if tos, err := ipv4.NewConn(s.conn).TOS(); err != nil || tos != 46<<2 {
+		t.Errorf("tos = %d, %v", tos, err)
+	}
+}
@@ -0,0 +1,44 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package udp
+
+import (
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortControl enables SO_REUSEPORT on the socket before it is bound, so several sockets can
+// listen on the same port (the kernel balances the datagrams between them)
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var err error
+	if controlErr := c.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); controlErr != nil {
+		return controlErr
+	}
+	return err
+}
+
+// socketBufferSize returns the usable kernel buffer size of the given socket option (SO_RCVBUF or
+// SO_SNDBUF)
+func socketBufferSize(conn bufferedConn, option int) (int, error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	if controlErr := rc.Control(func(fd uintptr) {
+		size, err = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, option)
+	}); controlErr != nil {
+		return 0, controlErr
+	}
+
+	// linux reports twice the requested size (the other half is its bookkeeping overhead)
+	if runtime.GOOS == "linux" {
+		size /= 2
+	}
+	return size, err
+}
@@ -0,0 +1,195 @@
+package udp
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when sending to a closed packet source
+var ErrClosed = errors.New("packet source closed")
+
+// PacketSender is the common interface of the packet senders (udp, tcp, tls, dtls, memory, or file)
+type PacketSender interface {
+	// Transmit sends the given packet bytes
+	Transmit(data []byte) error
+
+	// Close releases the sender resources
+	Close() error
+}
+
+// PacketSource is the common interface of the packet sources (udp, tcp, tls, dtls, memory, file, or
+// stdin), so the ccx pipeline can be wired to any source
+type PacketSource interface {
+	// DataHandler registers the handler to call with every received packet
+	DataHandler(handler DataUpdateFunc)
+
+	// Serve delivers the packets to the handler until the source is exhausted or closed
+	Serve() error
+
+	// Close stops delivering packets
+	Close() error
+}
+
+// ensure every sender and source implements the interfaces
+var (
+	_ PacketSender = (*Sender)(nil)
+	_ PacketSender = (*MemorySender)(nil)
+	_ PacketSender = (*FileSender)(nil)
+	_ PacketSource = (*Receiver)(nil)
+	_ PacketSource = (*MemorySource)(nil)
+	_ PacketSource = (*FileSource)(nil)
+)
+
+// MemorySource is an in-memory channel packet source (e.g. to test the ccx pipeline without sockets)
+type MemorySource struct {
+	options     *MemorySourceOptions
+	mutex       sync.RWMutex
+	closed      bool
+	pushes      sync.WaitGroup
+	packets     chan *DataUpdate
+	done        chan struct{}
+	dataHandler DataUpdateFunc
+}
+
+// MemorySourceOptions provides the instance options
+type MemorySourceOptions struct {
+	// Buffer is the number of pushed packets buffered until served (Push blocks while full)
+	Buffer int
+
+	// RemoteIP and RemotePort are the source address of the pushed packets
+	RemoteIP   string
+	RemotePort int
+}
+
+// NewMemorySource creates a new instance
+func NewMemorySource(options *MemorySourceOptions) *MemorySource {
+	// apply the default option values
+	if options.Buffer <= 0 {
+		options.Buffer = 100
+	}
+	if options.RemoteIP == "" {
+		options.RemoteIP = "127.0.0.1"
+	}
+
+	// return the new instance
+	return &MemorySource{
+		options: options,
+		packets: make(chan *DataUpdate, options.Buffer),
+		done:    make(chan struct{}),
+	}
+}
+
+// DataHandler registers the update handler to call with every pushed packet
+func (m *MemorySource) DataHandler(handler DataUpdateFunc) {
+	m.dataHandler = handler
+}
+
+// Push queues the given packet bytes received now
+func (m *MemorySource) Push(data []byte) error {
+	return m.PushUpdate(&DataUpdate{
+		TS:         time.Now(),
+		RemoteIP:   m.options.RemoteIP,
+		RemotePort: m.options.RemotePort,
+		Data:       data,
+	})
+}
+
+// PushUpdate queues the given packet (e.g. with a specific receive time or source address)
+func (m *MemorySource) PushUpdate(du *DataUpdate) error {
+	// if already closed
+	m.mutex.RLock()
+	if m.closed {
+		m.mutex.RUnlock()
+		return ErrClosed
+	}
+	m.pushes.Add(1)
+	m.mutex.RUnlock()
+	defer m.pushes.Done()
+
+	// wait for room in the buffer without holding the lock (so a push blocked on a full buffer does
+	// not block Close, and is abandoned once closed)
+	select {
+	case m.packets <- du:
+		return nil
+	case <-m.done:
+		return ErrClosed
+	}
+}
+
+// Serve delivers the pushed packets until the source is closed (and the queued packets are delivered)
+func (m *MemorySource) Serve() error {
+	for du := range m.packets {
+		if m.dataHandler != nil {
+			dispatch(m.dataHandler, du)
+		}
+	}
+	return nil
+}
+
+// Close stops accepting pushed packets
+func (m *MemorySource) Close() error {
+	// stop accepting pushed packets, and abandon the blocked pushes
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.done)
+	m.mutex.Unlock()
+
+	// end Serve once the pushes in progress have returned (and the queued packets are delivered)
+	m.pushes.Wait()
+	close(m.packets)
+	return nil
+}
+
+// MemorySender records every transmitted packet in memory (and pushes it to a memory source if given)
+type MemorySender struct {
+	options *MemorySenderOptions
+	mutex   sync.Mutex
+	packets [][]byte
+}
+
+// MemorySenderOptions provides the instance options
+type MemorySenderOptions struct {
+	// Source receives every transmitted packet (not forwarded if nil)
+	Source *MemorySource
+}
+
+// NewMemorySender creates a new instance
+func NewMemorySender(options *MemorySenderOptions) *MemorySender {
+	// return the new instance
+	return &MemorySender{
+		options: options,
+	}
+}
+
+// Transmit records a copy of the given packet bytes (and pushes it to the memory source if given)
+func (m *MemorySender) Transmit(data []byte) error {
+	packet := make([]byte, len(data))
+	copy(packet, data)
+
+	m.mutex.Lock()
+	m.packets = append(m.packets, packet)
+	m.mutex.Unlock()
+
+	if m.options.Source != nil {
+		return m.options.Source.Push(packet)
+	}
+	return nil
+}
+
+// Packets returns every transmitted packet
+func (m *MemorySender) Packets() [][]byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([][]byte{}, m.packets...)
+}
+
+// Close does nothing (the memory source is left open)
+func (m *MemorySender) Close() error {
+	return nil
+}
@@ -0,0 +1,186 @@
+package udp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collect serves the given source and returns every delivered packet
+func collect(t *testing.T, source PacketSource) []*DataUpdate {
+	updates := []*DataUpdate{}
+	source.DataHandler(func(du *DataUpdate) {
+		updates = append(updates, du)
+	})
+	if err := source.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	return updates
+}
+
+func TestMemoryTransport(t *testing.T) {
+	// the memory sender records the packets and forwards them to the memory source
+	source := NewMemorySource(&MemorySourceOptions{})
+	var sender PacketSender = NewMemorySender(&MemorySenderOptions{Source: source})
+	for _, packet := range []string{"one", "two"} {
+		if err := sender.Transmit([]byte(packet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source.Close()
+	if err := sender.Transmit([]byte("three")); err != ErrClosed {
+		t.Errorf("Transmit() after Close() error = %v", err)
+	}
+
+	// the queued packets are delivered until the source is closed
+	updates := collect(t, source)
+	if len(updates) != 2 || string(updates[0].Data) != "one" || updates[1].RemoteIP != "127.0.0.1" {
+		t.Fatalf("updates = %+v", updates)
+	}
+	if packets := sender.(*MemorySender).Packets(); len(packets) != 3 {
+		t.Errorf("recorded %d packets", len(packets))
+	}
+}
+
+func TestMemorySourceCloseBlockedPush(t *testing.T) {
+	// a push blocked on the full buffer does not block Close, and returns once closed
+	source := NewMemorySource(&MemorySourceOptions{Buffer: 1})
+	source.Push([]byte("one"))
+	pushed := make(chan error)
+	go func() {
+		pushed <- source.Push([]byte("two"))
+	}()
+	closed := make(chan struct{})
+	go func() {
+		source.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() blocked by a pending push")
+	}
+	if err := <-pushed; err != ErrClosed {
+		t.Errorf("Push() error = %v", err)
+	}
+
+	// the queued packet is still delivered
+	if updates := collect(t, source); len(updates) != 1 || string(updates[0].Data) != "one" {
+		t.Fatalf("updates = %+v", updates)
+	}
+}
+
+func TestHexFileSource(t *testing.T) {
+	// the file sender writes lines that the file source replays
+	out := &bytes.Buffer{}
+	sender, err := NewFileSender(&FileSenderOptions{Writer: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.Transmit([]byte{0x00, 0x01, 0xAB})
+	out.WriteString("# a comment\n\n00 11:22 # trailing comment\nnot hex\n")
+
+	updates := collect(t, NewFileSource(&FileSourceOptions{Reader: strings.NewReader(out.String())}))
+	if len(updates) != 2 || !bytes.Equal(updates[0].Data, []byte{0x00, 0x01, 0xAB}) ||
+		!bytes.Equal(updates[1].Data, []byte{0x00, 0x11, 0x22}) {
+		t.Fatalf("updates = %+v", updates)
+	}
+}
+
+// pcapFrame returns an ethernet ipv4 udp frame of the given payload
+func pcapFrame(srcPort, dstPort uint16, payload string) []byte {
+	frame := make([]byte, 14+20+8)
+	binary.BigEndian.PutUint16(frame[12:], 0x0800)
+	ip := frame[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+8+len(payload)))
+	ip[9] = 17
+	copy(ip[12:], []byte{10, 0, 0, 7})
+	udp := ip[20:]
+	binary.BigEndian.PutUint16(udp, srcPort)
+	binary.BigEndian.PutUint16(udp[2:], dstPort)
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(payload)))
+	return append(frame, payload...)
+}
+
+func TestPcapFileSource(t *testing.T) {
+	// create a little-endian microsecond ethernet capture
+	pcap := &bytes.Buffer{}
+	binary.Write(pcap, binary.LittleEndian, []uint32{pcapMagicMicros, 0x00040002, 0, 0, 65535, linkTypeEthernet})
+	for i, frame := range [][]byte{
+		pcapFrame(5000, 9999, "first"),
+		pcapFrame(5000, 53, "dns"),
+		pcapFrame(5001, 9999, "second"),
+	} {
+		binary.Write(pcap, binary.LittleEndian, []uint32{1700000000, uint32(i * 1000), uint32(len(frame)), uint32(len(frame))})
+		pcap.Write(frame)
+	}
+
+	// only the datagrams sent to the port are replayed, with their capture time and source address
+	updates := collect(t, NewFileSource(&FileSourceOptions{Reader: pcap, Port: 9999}))
+	if len(updates) != 2 || string(updates[0].Data) != "first" || string(updates[1].Data) != "second" {
+		t.Fatalf("updates = %+v", updates)
+	}
+	if du := updates[1]; du.RemoteIP != "10.0.0.7" || du.RemotePort != 5001 || du.TS.UnixNano() != 1700000000002000000 {
+		t.Errorf("update = %+v", du)
+	}
+}
+
+// errReader fails every read
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestUnreadableFileSource(t *testing.T) {
+	// the read errors are returned (e.g. a directory path)
+	if err := NewFileSource(&FileSourceOptions{Reader: errReader{}}).Serve(); err == nil || err.Error() != "read failed" {
+		t.Errorf("Serve() error = %v", err)
+	}
+	if err := NewFileSource(&FileSourceOptions{Path: t.TempDir()}).Serve(); err == nil {
+		t.Error("Serve() of a directory succeeded")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	// the datagrams of a capture are dropped by the source filters of the receiver
+	pcap := &bytes.Buffer{}
+	binary.Write(pcap, binary.LittleEndian, []uint32{pcapMagicMicros, 0x00040002, 0, 0, 65535, linkTypeEthernet})
+	for _, frame := range [][]byte{pcapFrame(5000, 9999, "first"), pcapFrame(5001, 9999, "second")} {
+		binary.Write(pcap, binary.LittleEndian, []uint32{1700000000, 0, uint32(len(frame)), uint32(len(frame))})
+		pcap.Write(frame)
+	}
+	dropped := []string{}
+	r := NewReceiver(&ReceiverOptions{Deny: []string{"10.0.0.0/8"}})
+	r.DropHandler(func(du *DataUpdate, reason string) {
+		dropped = append(dropped, reason)
+	})
+	r.DataHandler(func(du *DataUpdate) {
+		panic("handler panic")
+	})
+	if err := r.Replay(NewFileSource(&FileSourceOptions{Reader: pcap})); err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 2 || dropped[0] != DeniedDropReason {
+		t.Errorf("dropped = %v", dropped)
+	}
+
+	// the handler panics are recovered
+	source := NewMemorySource(&MemorySourceOptions{})
+	source.Push([]byte("first"))
+	source.Push([]byte("second"))
+	source.Close()
+	handled := 0
+	r = NewReceiver(&ReceiverOptions{})
+	r.DataHandler(func(du *DataUpdate) {
+		handled++
+		panic("handler panic")
+	})
+	if err := r.Replay(source); err != nil || handled != 2 {
+		t.Errorf("Replay() = %v, handled %d packets", err, handled)
+	}
+}
@@ -0,0 +1,269 @@
+package udp
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// transport name constants
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+)
+
+// frameHeaderSize is the size of the big-endian length prefix of every packet sent over a stream
+// transport (tcp or tls)
+const frameHeaderSize = 2
+
+// Transport is the common interface of the udp, tcp, tls, and dtls transports, used by the senders
+// and receivers to open their sockets (or sessions) and to send and receive the packets over them
+type Transport interface {
+	// Network returns the transport name (udp, tcp, tls, or dtls)
+	Network() string
+
+	// Dial opens the socket (or the session) to the given 'host:port' destination, calling setup with
+	// the underlying socket before any handshake (e.g. to set the socket options)
+	Dial(dst string, setup func(conn net.Conn) error) (net.Conn, error)
+
+	// Listen listens to the sessions on the given address (the listener is nil for the plain udp
+	// transport, which receives the datagrams on its own sockets)
+	Listen(addr string) (net.Listener, error)
+
+	// WritePacket sends a single packet over the given socket (or session)
+	WritePacket(conn net.Conn, data []byte) error
+
+	// ReadPacket receives the next packet of the given session into the given buffer, and returns its
+	// length
+	ReadPacket(conn net.Conn, buf []byte) (int, error)
+}
+
+// ensure the udp, tcp (and tls), and dtls transports implement the transport interface
+var (
+	_ Transport = (*udpTransport)(nil)
+	_ Transport = (*tcpTransport)(nil)
+	_ Transport = (*dtlsTransport)(nil)
+)
+
+// NewTransport creates the transport of the given name (udp if empty), with the certificate files of
+// the tls transport, or over dtls 1.2 sessions if the dtls options are given (udp only)
+func NewTransport(name string, files *util.TLSFiles, dtlsOptions *DTLSOptions) (Transport, error) {
+	// validate the transport
+	name, err := ParseTransport(name)
+	if err != nil {
+		return nil, err
+	}
+	if isStream(name) && dtlsOptions != nil {
+		return nil, fmt.Errorf("DTLS is only supported by the udp transport")
+	}
+
+	// create the transport
+	switch {
+	case dtlsOptions != nil:
+		return &dtlsTransport{options: dtlsOptions}, nil
+	case name == TransportTCP:
+		return &tcpTransport{}, nil
+	case name == TransportTLS:
+		if files == nil {
+			files = &util.TLSFiles{}
+		}
+		return &tcpTransport{tls: files}, nil
+	default:
+		return &udpTransport{}, nil
+	}
+}
+
+// ParseTransport validates the given transport name (udp if empty)
+func ParseTransport(name string) (string, error) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "":
+		return TransportUDP, nil
+	case TransportUDP, TransportTCP, TransportTLS:
+		return name, nil
+	default:
+		return "", fmt.Errorf("Invalid transport '%s' (expected udp, tcp, or tls)", name)
+	}
+}
+
+// isStream returns whether the given transport frames the packets over a byte stream
+func isStream(transport string) bool {
+	return transport == TransportTCP || transport == TransportTLS
+}
+
+// writeFrame writes the given packet with its length prefix (in a single write)
+func writeFrame(w io.Writer, data []byte) error {
+	if len(data) > 0xFFFF {
+		return fmt.Errorf("packet too large to frame (%d bytes)", len(data))
+	}
+	frame := make([]byte, frameHeaderSize+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	copy(frame[frameHeaderSize:], data)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame reads the next length prefixed packet into the given buffer, and returns its length
+func readFrame(r io.Reader, buf []byte) (int, error) {
+	// read the length prefix
+	header := [frameHeaderSize]byte{}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	length := int(binary.BigEndian.Uint16(header[:]))
+	if length > len(buf) {
+		return 0, fmt.Errorf("packet too large (%d bytes)", length)
+	}
+
+	// read the packet
+	return io.ReadFull(r, buf[:length])
+}
+
+// udpTransport sends and receives the plain udp datagrams
+type udpTransport struct{}
+
+// Network returns the transport name
+func (t *udpTransport) Network() string {
+	return TransportUDP
+}
+
+// Dial creates the udp socket to the given destination
+func (t *udpTransport) Dial(dst string, setup func(conn net.Conn) error) (net.Conn, error) {
+	conn, err := net.DialTimeout("udp", dst, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := setup(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Listen returns a nil listener (the receiver listens with its own udp sockets)
+func (t *udpTransport) Listen(addr string) (net.Listener, error) {
+	return nil, nil
+}
+
+// WritePacket sends the given packet as a single datagram
+func (t *udpTransport) WritePacket(conn net.Conn, data []byte) error {
+	_, err := conn.Write(data)
+	return err
+}
+
+// ReadPacket receives the next datagram
+func (t *udpTransport) ReadPacket(conn net.Conn, buf []byte) (int, error) {
+	return conn.Read(buf)
+}
+
+// tcpTransport sends and receives the length prefixed packets over tcp connections (or over tls
+// connections if the certificate files are given)
+type tcpTransport struct {
+	tls *util.TLSFiles
+}
+
+// Network returns the transport name
+func (t *tcpTransport) Network() string {
+	if t.tls != nil {
+		return TransportTLS
+	}
+	return TransportTCP
+}
+
+// Dial connects to the given destination (and performs the tls handshake if enabled)
+func (t *tcpTransport) Dial(dst string, setup func(conn net.Conn) error) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", dst, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := setup(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if t.tls == nil {
+		return conn, nil
+	}
+
+	// perform the tls handshake (verifying the certificate of the destination host)
+	host, _, _ := net.SplitHostPort(dst)
+	config, err := streamTLSConfig(t.tls, false, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// Listen listens to the tcp (or tls) connections on the given address
+func (t *tcpTransport) Listen(addr string) (net.Listener, error) {
+	if t.tls == nil {
+		return net.Listen("tcp", addr)
+	}
+	config, err := streamTLSConfig(t.tls, true, "")
+	if err != nil {
+		return nil, err
+	}
+	return tls.Listen("tcp", addr, config)
+}
+
+// WritePacket sends the given packet with its length prefix
+func (t *tcpTransport) WritePacket(conn net.Conn, data []byte) error {
+	return writeFrame(conn, data)
+}
+
+// ReadPacket receives the next length prefixed packet
+func (t *tcpTransport) ReadPacket(conn net.Conn, buf []byte) (int, error) {
+	return readFrame(conn, buf)
+}
+
+// streamTLSConfig creates the tls configuration of the receiver (server) or the sender (client) from
+// the given certificate files: the receiver needs a certificate and key (and requires a client
+// certificate signed by the CA file if given), and the sender verifies the receiver certificate
+// against the CA file (and sends its certificate if given)
+func streamTLSConfig(files *util.TLSFiles, server bool, serverName string) (*tls.Config, error) {
+	if files == nil {
+		files = &util.TLSFiles{}
+	}
+	config, err := util.LoadTLSConfig(files)
+	if err != nil {
+		return nil, err
+	}
+	if server {
+		if len(config.Certificates) == 0 {
+			return nil, fmt.Errorf("TLS receiver needs a certificate and key file")
+		}
+		if config.RootCAs != nil {
+			config.ClientCAs = config.RootCAs
+			config.RootCAs = nil
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else {
+		config.ServerName = serverName
+	}
+	return config, nil
+}
+
+// remoteIPPort returns the ip-address and port of the given udp or tcp address
+func remoteIPPort(addr net.Addr) (net.IP, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port
+	case *net.TCPAddr:
+		return a.IP, a.Port
+	default:
+		return nil, 0
+	}
+}
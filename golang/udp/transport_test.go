@@ -0,0 +1,130 @@
+package udp
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+func TestFrames(t *testing.T) {
+	// consecutive frames are split at their length prefixes
+	stream := &bytes.Buffer{}
+	for _, packet := range []string{"first", "", "third packet"} {
+		if err := writeFrame(stream, []byte(packet)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := make([]byte, DefaultMaxDatagramSize)
+	for _, want := range []string{"first", "", "third packet"} {
+		n, err := readFrame(stream, buf)
+		if err != nil || string(buf[:n]) != want {
+			t.Fatalf("readFrame() = %q, %v, want %q", buf[:n], err, want)
+		}
+	}
+
+	// frames larger than the buffer are rejected
+	writeFrame(stream, make([]byte, 16))
+	if _, err := readFrame(stream, make([]byte, 8)); err == nil {
+		t.Error("oversized frame accepted")
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	for name, want := range map[string]string{"": "udp", "UDP": "udp", "tcp": "tcp", " tls ": "tls"} {
+		if got, err := ParseTransport(name); err != nil || got != want {
+			t.Errorf("ParseTransport(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseTransport("sctp"); err == nil {
+		t.Error("ParseTransport(\"sctp\") succeeded")
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	loopback(t, &ReceiverOptions{Transport: TransportTCP}, &SenderOptions{Transport: TransportTCP})
+}
+
+func TestTLSTransport(t *testing.T) {
+	// the sender verifies the receiver certificate against the ca file
+	certFile, keyFile := writeCertificate(t)
+	loopback(t,
+		&ReceiverOptions{Transport: TransportTLS, TLS: &util.TLSFiles{CertFile: certFile, KeyFile: keyFile}},
+		&SenderOptions{Transport: TransportTLS, TLS: &util.TLSFiles{CAFile: certFile}})
+}
+
+func TestNewTransport(t *testing.T) {
+	// every transport name (and dtls over udp) creates its own transport implementation
+	for _, tt := range []struct {
+		name    string
+		dtls    *DTLSOptions
+		network string
+	}{
+		{"", nil, "udp"},
+		{"tcp", nil, "tcp"},
+		{"tls", nil, "tls"},
+		{"udp", &DTLSOptions{PSK: []byte("secret")}, "dtls"},
+	} {
+		transport, err := NewTransport(tt.name, nil, tt.dtls)
+		if err != nil || transport.Network() != tt.network {
+			t.Errorf("NewTransport(%q) = %v, %v, want %s", tt.name, transport, err, tt.network)
+		}
+	}
+
+	// dtls is only supported over udp
+	if _, err := NewTransport("tcp", nil, &DTLSOptions{PSK: []byte("secret")}); err == nil {
+		t.Error("NewTransport(\"tcp\") with dtls succeeded")
+	}
+}
+
+// failingListener fails every accept (e.g. with too many open files) until closed
+type failingListener struct {
+	accepts int32
+	once    sync.Once
+	closed  chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	atomic.AddInt32(&l.accepts, 1)
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+		return nil, syscall.EMFILE
+	}
+}
+
+func (l *failingListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *failingListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	// the persistent accept errors are retried with a backoff instead of spinning
+	listener := &failingListener{closed: make(chan struct{})}
+	r := NewReceiver(&ReceiverOptions{Transport: TransportTCP})
+	r.listener = listener
+	done := make(chan error)
+	go func() {
+		done <- r.serveListener()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	r.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&listener.accepts); n > 10 {
+		t.Errorf("accepts = %d, want a backoff", n)
+	}
+}
@@ -64,3 +64,27 @@ func MACBytesToString(bytes [6]byte) string {
 	return fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X",
 		bytes[0], bytes[1], bytes[2], bytes[3], bytes[4], bytes[5])
 }
+
+// NormalizeMAC formats the given mac-address (with ':', '-', '.', or no delimiters) in the
+// uppercase ':' delimited format (invalid mac-addresses are returned unchanged)
+func NormalizeMAC(mac string) string {
+	normalized, err := ParseMAC(mac)
+	if err != nil {
+		return mac
+	}
+	return normalized
+}
+
+// ParseMAC formats the given mac-address (with ':', '-', '.', or no delimiters) in the uppercase
+// ':' delimited format, or returns an error if the mac-address is invalid
+func ParseMAC(mac string) (string, error) {
+	// remove any delimiters
+	macHex := strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac)
+
+	// convert to bytes and back to the formatted string
+	bytes, err := MACAddrToBytes(macHex)
+	if err != nil {
+		return "", err
+	}
+	return MACBytesToString(bytes), nil
+}
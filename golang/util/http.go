@@ -0,0 +1,13 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteJSON writes the given value as the json response body with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseNetwork parses the given cidr network or ip-address (as a single address network)
+func ParseNetwork(s string) (*net.IPNet, error) {
+	// if the value is an ip-address, convert it to a single address network
+	cidr := s
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid ip-address '%s'", s)
+		}
+		if ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+
+	// parse the cidr network
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid cidr network '%s'", s)
+	}
+	return network, nil
+}
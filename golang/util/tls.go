@@ -0,0 +1,47 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSFiles defines the pem file paths used to create a tls configuration
+type TLSFiles struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// LoadTLSConfig creates a tls configuration from the given pem files
+func LoadTLSConfig(files *TLSFiles) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: files.InsecureSkipVerify,
+	}
+
+	// load the trusted certificate authorities if given
+	if files.CAFile != "" {
+		pem, err := os.ReadFile(files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA file '%s'", files.CAFile)
+		}
+	}
+
+	// load the client certificate if given
+	if files.CertFile != "" || files.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	// return the tls configuration
+	return config, nil
+}
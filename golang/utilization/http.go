@@ -0,0 +1,36 @@
+package utilization
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/EmanateWireless/emanate-udp-tools/golang/util"
+)
+
+// Handler returns the http handler serving the 'GET /utilization?period=hour|day&tag={mac}' json endpoint
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the GET method is supported
+		if r.Method != http.MethodGet {
+			util.WriteJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		// validate the summary period (daily by default)
+		period := r.URL.Query().Get("period")
+		if period == "" {
+			period = DayPeriod
+		}
+		if period != HourPeriod && period != DayPeriod {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]string{"error": "period must be 'hour' or 'day'"})
+			return
+		}
+
+		// return the summaries of every tag (or only the given tag)
+		tag := r.URL.Query().Get("tag")
+		if tag != "" {
+			tag = util.NormalizeMAC(tag)
+		}
+		util.WriteJSON(w, http.StatusOK, t.Summaries(period, tag, time.Now()))
+	})
+}
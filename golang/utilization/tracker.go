@@ -0,0 +1,312 @@
+package utilization
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// summary period constants
+const (
+	HourPeriod = "hour"
+	DayPeriod  = "day"
+)
+
+// tracker default constants
+const (
+	DefaultMaxGap    = time.Hour
+	DefaultRetention = 7 * 24 * time.Hour
+)
+
+// States defines the utility state short names (see ccx.UtilStateNames) in report order
+var States = []string{"active", "idle", "off", "unplugged"}
+
+// Tracker accumulates the time each tag spends in each utility state in hourly buckets
+type Tracker struct {
+	mutex   sync.Mutex
+	options *TrackerOptions
+	tags    map[string]*tagState
+	expired time.Time
+}
+
+// TrackerOptions provides the instance options
+type TrackerOptions struct {
+	// MaxGap is the longest time between packets attributed to the last reported state
+	MaxGap time.Duration
+
+	// Retention is how long the hourly buckets (and the tags that stopped reporting) are kept
+	Retention time.Duration
+
+	// Location is the time zone of the hourly and daily summaries (the hours start on the hour of the
+	// time zone, e.g. at half past the hour in UTC for a +05:30 offset)
+	Location *time.Location
+}
+
+// Summary defines the utilization of a single tag during a single hour or day
+type Summary struct {
+	TagMAC             string             `json:"tag_mac"`
+	Period             string             `json:"period"`
+	Start              time.Time          `json:"start"`
+	Seconds            map[string]float64 `json:"seconds"`
+	Transitions        int                `json:"transitions"`
+	UtilizationPercent float64            `json:"utilization_percent"`
+}
+
+// tagState tracks the utility state of a single tag
+type tagState struct {
+	state    string
+	lastSeen time.Time
+	hours    map[int64]*bucket
+}
+
+// expiryInterval is the interval between the removals of the expired buckets and tags
+const expiryInterval = time.Hour
+
+// bucket accumulates the time in each state during a single hour
+type bucket struct {
+	seconds     map[string]float64
+	transitions int
+}
+
+// NewTracker creates a new instance
+func NewTracker(options *TrackerOptions) *Tracker {
+	// apply the default option values
+	if options.MaxGap <= 0 {
+		options.MaxGap = DefaultMaxGap
+	}
+	if options.Retention <= 0 {
+		options.Retention = DefaultRetention
+	}
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+
+	// return the new instance
+	return &Tracker{
+		options: options,
+		tags:    map[string]*tagState{},
+	}
+}
+
+// Update records the utility state reported by the given tag at the given time
+func (t *Tracker) Update(tagMAC string, ts time.Time, state string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// get the tag state (or create it if this is the first report)
+	s, ok := t.tags[tagMAC]
+	if !ok {
+		t.tags[tagMAC] = &tagState{state: state, lastSeen: ts, hours: map[int64]*bucket{}}
+		return
+	}
+
+	// ignore the reports received out of order
+	if ts.Before(s.lastSeen) {
+		return
+	}
+
+	// the time since the last report is spent in the last reported state (up to the maximum gap)
+	t.addTime(s.hours, s.state, s.lastSeen, t.end(s, ts))
+
+	// count the state transition
+	if state != s.state {
+		t.hourBucket(s.hours, ts).transitions++
+		s.state = state
+	}
+	s.lastSeen = ts
+
+	// remove the expired hourly buckets of every tag (and the tags that stopped reporting) at most
+	// once per expiry interval
+	if ts.Sub(t.expired) >= expiryInterval {
+		t.expire(ts)
+		t.expired = ts
+	}
+}
+
+// expire removes the hourly buckets older than the retention at the given time, and the tags that
+// have not reported since
+func (t *Tracker) expire(now time.Time) {
+	cutoff := now.Add(-t.options.Retention)
+	expired := t.hourStart(cutoff).Unix()
+	for tag, s := range t.tags {
+		if s.lastSeen.Before(cutoff) {
+			delete(t.tags, tag)
+			continue
+		}
+		for hour := range s.hours {
+			if hour < expired {
+				delete(s.hours, hour)
+			}
+		}
+	}
+}
+
+// Summaries returns the hourly or daily utilization summaries of every tag (or only the given tag if
+// not empty) up to the given time, sorted by tag mac-address and period start
+func (t *Tracker) Summaries(period string, tagMAC string, now time.Time) []*Summary {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	summaries := []*Summary{}
+	for tag, s := range t.tags {
+		if tagMAC != "" && tag != tagMAC {
+			continue
+		}
+
+		// copy the hourly buckets and add the time spent in the current state so far
+		hours := map[int64]*bucket{}
+		for hour, b := range s.hours {
+			hours[hour] = b.copy()
+		}
+		if now.After(s.lastSeen) {
+			t.addTime(hours, s.state, s.lastSeen, t.end(s, now))
+		}
+
+		// merge the hourly buckets into the period summaries
+		byStart := map[time.Time]*Summary{}
+		for hour, b := range hours {
+			start := t.periodStart(period, time.Unix(hour, 0))
+			summary, ok := byStart[start]
+			if !ok {
+				summary = &Summary{
+					TagMAC:  tag,
+					Period:  period,
+					Start:   start,
+					Seconds: map[string]float64{},
+				}
+				byStart[start] = summary
+				summaries = append(summaries, summary)
+			}
+			for state, seconds := range b.seconds {
+				summary.Seconds[state] += seconds
+			}
+			summary.Transitions += b.transitions
+		}
+	}
+
+	// calculate the active utilization percentages
+	for _, summary := range summaries {
+		total := 0.0
+		for _, seconds := range summary.Seconds {
+			total += seconds
+		}
+		if total > 0 {
+			summary.UtilizationPercent = 100 * summary.Seconds["active"] / total
+		}
+	}
+
+	// sort the summaries
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].TagMAC != summaries[j].TagMAC {
+			return summaries[i].TagMAC < summaries[j].TagMAC
+		}
+		return summaries[i].Start.Before(summaries[j].Start)
+	})
+
+	// return the summaries
+	return summaries
+}
+
+// Dump prints the utilization summaries of every tag for the given hour or day to the console
+func (t *Tracker) Dump(period string, start time.Time) {
+	// find the summaries of the given period
+	start = t.periodStart(period, start)
+	summaries := []*Summary{}
+	for _, summary := range t.Summaries(period, "", time.Now()) {
+		if summary.Start.Equal(start) {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	// print the summaries
+	title := fmt.Sprintf("UTILIZATION SUMMARY (%s starting %s)", period, start.Format(time.RFC3339))
+	fmt.Printf("\n%s\n", title)
+	fmt.Printf("%s\n\n", strings.Repeat("=", len(title)))
+	if len(summaries) == 0 {
+		fmt.Printf("  - No utility state reports\n\n")
+		return
+	}
+	for _, summary := range summaries {
+		fmt.Printf("  - Tag '%s' = %.1f%% utilized (%d transitions)\n", summary.TagMAC, summary.UtilizationPercent, summary.Transitions)
+		for _, state := range States {
+			d := time.Duration(summary.Seconds[state] * float64(time.Second)).Round(time.Second)
+			fmt.Printf("      %-10s %s\n", state, d)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// RunReports prints the hourly summaries at the end of every hour and the daily summaries at the
+// end of every day (this function never returns)
+func (t *Tracker) RunReports() {
+	lastHour := t.periodStart(HourPeriod, time.Now())
+	lastDay := t.periodStart(DayPeriod, time.Now())
+	for now := range time.Tick(time.Minute) {
+		if hour := t.periodStart(HourPeriod, now); hour.After(lastHour) {
+			t.Dump(HourPeriod, lastHour)
+			lastHour = hour
+		}
+		if day := t.periodStart(DayPeriod, now); day.After(lastDay) {
+			t.Dump(DayPeriod, lastDay)
+			lastDay = day
+		}
+	}
+}
+
+// end returns the end of the time attributed to the last reported state of the given tag
+func (t *Tracker) end(s *tagState, ts time.Time) time.Time {
+	if limit := s.lastSeen.Add(t.options.MaxGap); ts.After(limit) {
+		return limit
+	}
+	return ts
+}
+
+// periodStart returns the start of the hour or day (in the tracker time zone) of the given time
+func (t *Tracker) periodStart(period string, ts time.Time) time.Time {
+	if period == DayPeriod {
+		ts = ts.In(t.options.Location)
+		return time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, t.options.Location)
+	}
+	return t.hourStart(ts)
+}
+
+// hourStart returns the start of the hour (in the tracker time zone) of the given time (time.Truncate
+// would truncate the hour of the UTC time instead)
+func (t *Tracker) hourStart(ts time.Time) time.Time {
+	ts = ts.In(t.options.Location)
+	return ts.Add(-time.Duration(ts.Minute())*time.Minute - time.Duration(ts.Second())*time.Second -
+		time.Duration(ts.Nanosecond()))
+}
+
+// addTime adds the time between the given times to the given state, split across the hourly buckets
+func (t *Tracker) addTime(hours map[int64]*bucket, state string, from time.Time, to time.Time) {
+	for from.Before(to) {
+		next := t.hourStart(from).Add(time.Hour)
+		if next.After(to) {
+			next = to
+		}
+		t.hourBucket(hours, from).seconds[state] += next.Sub(from).Seconds()
+		from = next
+	}
+}
+
+// hourBucket returns the bucket of the hour of the given time (or creates it if needed)
+func (t *Tracker) hourBucket(hours map[int64]*bucket, ts time.Time) *bucket {
+	hour := t.hourStart(ts).Unix()
+	b, ok := hours[hour]
+	if !ok {
+		b = &bucket{seconds: map[string]float64{}}
+		hours[hour] = b
+	}
+	return b
+}
+
+func (b *bucket) copy() *bucket {
+	c := &bucket{seconds: map[string]float64{}, transitions: b.transitions}
+	for state, seconds := range b.seconds {
+		c.seconds[state] = seconds
+	}
+	return c
+}
@@ -0,0 +1,97 @@
+package utilization
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummaries(t *testing.T) {
+	tracker := NewTracker(&TrackerOptions{MaxGap: 30 * time.Minute, Location: time.UTC})
+	tag := "11:22:33:44:55:66"
+
+	// active from 09:30 to 10:15, idle until 10:45, then no reports for 2 hours (only the
+	// maximum gap is attributed to the last state), then off
+	start := time.Date(2026, 1, 2, 9, 30, 0, 0, time.UTC)
+	tracker.Update(tag, start, "active")
+	tracker.Update(tag, start.Add(30*time.Minute), "active")
+	tracker.Update(tag, start.Add(45*time.Minute), "idle")
+	tracker.Update(tag, start.Add(75*time.Minute), "idle")
+	tracker.Update(tag, start.Add(195*time.Minute), "off")
+
+	hours := tracker.Summaries(HourPeriod, "", start.Add(195*time.Minute))
+	want := []struct {
+		hour        int
+		active      float64
+		idle        float64
+		transitions int
+	}{
+		{9, 1800, 0, 0},
+		{10, 900, 2700, 1},
+		{11, 0, 900, 0},
+		{12, 0, 0, 1},
+	}
+	if len(hours) != len(want) {
+		t.Fatalf("hourly summaries = %d, want %d", len(hours), len(want))
+	}
+	for i, w := range want {
+		s := hours[i]
+		if s.Start.Hour() != w.hour || s.Seconds["active"] != w.active || s.Seconds["idle"] != w.idle || s.Transitions != w.transitions {
+			t.Errorf("summary #%d = %+v, want %+v", i, s, w)
+		}
+	}
+
+	// the daily summary includes every hour
+	days := tracker.Summaries(DayPeriod, tag, start.Add(195*time.Minute))
+	if len(days) != 1 || days[0].Seconds["active"] != 2700 || days[0].Seconds["idle"] != 3600 || days[0].Transitions != 2 {
+		t.Fatalf("daily summaries = %+v", days)
+	}
+	if p := days[0].UtilizationPercent; p < 42.8 || p > 42.9 {
+		t.Errorf("utilization = %.2f%%, want 42.86%%", p)
+	}
+
+	// the time in the current state is included up to the given time
+	days = tracker.Summaries(DayPeriod, tag, start.Add(205*time.Minute))
+	if len(days) != 1 || days[0].Seconds["off"] != 600 {
+		t.Fatalf("daily summaries = %+v", days)
+	}
+}
+
+func TestSummariesLocation(t *testing.T) {
+	// the hours start at half past the hour in UTC for a +05:30 offset
+	location := time.FixedZone("IST", 5*3600+1800)
+	tracker := NewTracker(&TrackerOptions{Location: location})
+	tag := "11:22:33:44:55:66"
+
+	// active from 10:15 to 11:15 local time
+	start := time.Date(2026, 1, 2, 10, 15, 0, 0, location)
+	tracker.Update(tag, start, "active")
+	tracker.Update(tag, start.Add(time.Hour), "active")
+
+	hours := tracker.Summaries(HourPeriod, tag, start.Add(time.Hour))
+	if len(hours) != 2 || !hours[0].Start.Equal(time.Date(2026, 1, 2, 10, 0, 0, 0, location)) ||
+		hours[0].Seconds["active"] != 2700 || hours[1].Seconds["active"] != 900 {
+		t.Fatalf("hourly summaries = %+v", hours)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	tracker := NewTracker(&TrackerOptions{Retention: 24 * time.Hour, Location: time.UTC})
+	start := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	// a tag that stopped reporting is removed once it is older than the retention
+	tracker.Update("11:22:33:44:55:66", start, "active")
+	tracker.Update("11:22:33:44:55:66", start.Add(time.Minute), "idle")
+	for i := 0; i <= 26; i++ {
+		tracker.Update("AA:BB:CC:DD:EE:FF", start.Add(time.Duration(i)*time.Hour), "active")
+	}
+	now := start.Add(26 * time.Hour)
+	if summaries := tracker.Summaries(DayPeriod, "11:22:33:44:55:66", now); len(summaries) != 0 {
+		t.Fatalf("expired tag summaries = %+v", summaries)
+	}
+
+	// the hourly buckets of a reporting tag older than the retention are removed
+	hours := tracker.Summaries(HourPeriod, "AA:BB:CC:DD:EE:FF", now)
+	if len(hours) == 0 || hours[0].Start.Before(now.Add(-24*time.Hour).Truncate(time.Hour)) {
+		t.Fatalf("hourly summaries = %+v", hours)
+	}
+}